@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	c "github.com/gookit/color"
+	"github.com/hashicorp/go-multierror"
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// blockEdit is a block of the blocks --json output as read by apply. Its text replaces the block's
+// once its original text is checked, which every edit must have. Original shadows Block.Original so
+// an edit without one can be told apart from the edit of an empty block.
+type blockEdit struct {
+	Block
+	Original *string `json:"original"`
+}
+
+// blockEditsDocument is the blocks --json output, a file given here is used for blocks without one
+type blockEditsDocument struct {
+	File   string      `json:"file"`
+	Blocks []blockEdit `json:"blocks"`
+}
+
+// readEdits returns the block edits of each file of input, either a unified diff or JSON block edits
+func readEdits(fs afero.Fs, input []byte) (map[string][]terrafmt.BlockEdit, error) {
+	trimmed := bytes.TrimSpace(input)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return readJSONEdits(trimmed)
+	}
+
+	patches, err := terrafmt.ParsePatch(input)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := map[string][]terrafmt.BlockEdit{}
+	for _, p := range patches {
+		if _, ok := edits[p.Filename]; ok {
+			return nil, fmt.Errorf("%s is patched more than once", p.Filename)
+		}
+
+		src, err := afero.ReadFile(fs, p.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		fileEdits, err := p.Edits(src, blocks.KindForFile(p.Filename))
+		if err != nil {
+			return nil, err
+		}
+		edits[p.Filename] = fileEdits
+	}
+
+	return edits, nil
+}
+
+// readJSONEdits reads any number of JSON values, each a blocks --json document, an array of edits, or a
+// single edit
+func readJSONEdits(input []byte) (map[string][]terrafmt.BlockEdit, error) {
+	var all []blockEdit
+
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading JSON edits: %w", err)
+		}
+
+		if raw[0] == '[' {
+			var list []blockEdit
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("error reading JSON edits: %w", err)
+			}
+			all = append(all, list...)
+
+			continue
+		}
+
+		var doc blockEditsDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("error reading JSON edits: %w", err)
+		}
+		if doc.Blocks == nil {
+			var edit blockEdit
+			if err := json.Unmarshal(raw, &edit); err != nil {
+				return nil, fmt.Errorf("error reading JSON edits: %w", err)
+			}
+			all = append(all, edit)
+
+			continue
+		}
+		for _, e := range doc.Blocks {
+			if e.File == "" {
+				e.File = doc.File
+			}
+			all = append(all, e)
+		}
+	}
+
+	edits := map[string][]terrafmt.BlockEdit{}
+	for _, e := range all {
+		if e.File == "" {
+			return nil, fmt.Errorf("the edit of block %d has no file", e.BlockNumber)
+		}
+		if e.BlockNumber < 1 {
+			return nil, fmt.Errorf("the edit of %s has no block_number", e.File)
+		}
+
+		if e.Original == nil {
+			return nil, fmt.Errorf("the edit of block %d of %s has no original text, edit the output of blocks --json", e.BlockNumber, e.File)
+		}
+		edits[e.File] = append(edits[e.File], terrafmt.BlockEdit{
+			Number:    e.BlockNumber,
+			StartLine: e.StartLine,
+			EndLine:   e.EndLine,
+			Original:  *e.Original,
+			Text:      e.Text,
+		})
+	}
+
+	return edits, nil
+}
+
+// applyEdits applies the edits of each file in turn, a file whose edits do not all match is left as
+// it is and the rest are still applied
+func applyEdits(ctx context.Context, fs afero.Fs, log *logrus.Logger, edits map[string][]terrafmt.BlockEdit, verbose bool, stderr io.Writer) error {
+	var errs *multierror.Error
+	for _, filename := range slices.Sorted(maps.Keys(edits)) {
+		res, err := terrafmt.ApplyEdits(ctx, fs, filename, edits[filename], terrafmt.Options{Log: log})
+		if err != nil {
+			errs = multierror.Append(errs, err)
+
+			continue
+		}
+
+		if verbose {
+			fmt.Fprint(stderr, c.Sprintf("<lightMagenta>%s</>: applied <yellow>%d</>/<yellow>%d</> edits!\n", res.Filename, res.ChangedBlocks, len(edits[filename])))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
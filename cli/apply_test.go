@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestReadEditsJSON(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name    string
+		input   string
+		files   map[string]int
+		wantErr bool
+	}{
+		{
+			name:  "blocks output",
+			input: `{"file": "a.md", "block_count": 2, "blocks": [{"block_number": 1, "start_line": 3, "end_line": 7, "original": "locals {\n}\n", "text": "locals {}\n"}, {"block_number": 2, "original": "", "text": "locals {}\n"}]}`,
+			files: map[string]int{"a.md": 2},
+		},
+		{
+			name:  "array of edits",
+			input: `[{"file": "a.md", "block_number": 1, "original": "x", "text": "locals {}\n"}, {"file": "b.go", "block_number": 3, "original": "x", "text": "locals {}\n"}]`,
+			files: map[string]int{"a.md": 1, "b.go": 1},
+		},
+		{
+			name:  "newline delimited edits",
+			input: "{\"file\": \"a.md\", \"block_number\": 1, \"original\": \"x\", \"text\": \"\"}\n{\"file\": \"a.md\", \"block_number\": 2, \"original\": \"x\", \"text\": \"\"}\n",
+			files: map[string]int{"a.md": 2},
+		},
+		{
+			name:    "edit without its original text",
+			input:   `[{"file": "a.md", "block_number": 1, "start_line": 3, "end_line": 7, "text": "locals {}\n"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "edit without a file",
+			input:   `[{"block_number": 1, "original": "x", "text": "locals {}\n"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			input:   `[{"file": "a.md",`,
+			wantErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			edits, err := readEdits(afero.NewMemMapFs(), []byte(testcase.input))
+			if testcase.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got none")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			if len(edits) != len(testcase.files) {
+				t.Errorf("Expected edits for %d files, got %d", len(testcase.files), len(edits))
+			}
+			for file, n := range testcase.files {
+				if len(edits[file]) != n {
+					t.Errorf("Expected %d edits for %s, got %d", n, file, len(edits[file]))
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	c "github.com/gookit/color"
 	"github.com/katbyte/terrafmt/lib/common"
 	"github.com/katbyte/terrafmt/lib/fmtverbs"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
 	"github.com/kylelemons/godebug/diff"
 	"github.com/spf13/afero"
 )
@@ -375,7 +376,7 @@ func TestCmdBlocksDefault(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			err := findBlocksInFile(fs, log, testcase.sourcefile, false, false, false, false, nil, &outB, &errB)
+			err := findBlocks(fs, []string{testcase.sourcefile}, fileOptions{Options: terrafmt.Options{Log: log}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -406,7 +407,7 @@ func TestCmdBlocksVerbose(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			err := findBlocksInFile(fs, log, testcase.sourcefile, true, false, false, false, nil, &outB, &errB)
+			err := findBlocks(fs, []string{testcase.sourcefile}, fileOptions{Options: terrafmt.Options{Log: log}, Verbose: true}, &outB, &errB)
 			actualStdErr := errB.String()
 			if err != nil {
 				t.Fatalf("Got an error when none was expected: %v", err)
@@ -440,7 +441,7 @@ func TestCmdBlocksZeroTerminated(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			err := findBlocksInFile(fs, log, testcase.sourcefile, false, true, false, false, nil, &outB, &errB)
+			err := findBlocks(fs, []string{testcase.sourcefile}, fileOptions{Options: terrafmt.Options{Log: log}, Blocks: FlagsBlocks{ZeroTerminated: true}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -472,10 +473,12 @@ func TestCmdBlocksJson(t *testing.T) {
 			for _, block := range testcase.expectedBlocks {
 				data.BlockCount++
 				blockData := Block{
+					File:        testcase.sourcefile,
 					BlockNumber: data.BlockCount,
 					StartLine:   block.startLine,
 					EndLine:     block.endLine,
 					Text:        block.text,
+					Original:    block.text,
 				}
 				data.Blocks = append(data.Blocks, blockData)
 			}
@@ -487,7 +490,7 @@ func TestCmdBlocksJson(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			err = findBlocksInFile(fs, log, testcase.sourcefile, false, false, true, false, nil, &outB, &errB)
+			err = findBlocks(fs, []string{testcase.sourcefile}, fileOptions{Options: terrafmt.Options{Log: log}, Blocks: FlagsBlocks{JSON: true}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -495,7 +498,7 @@ func TestCmdBlocksJson(t *testing.T) {
 				t.Fatalf("Got an error when none was expected: %v", err)
 			}
 
-			if !equivalentJSON([]byte(actualStdOut), expected) {
+			if !equivalentJSON(withoutPositions(t, actualStdOut), expected) {
 				t.Errorf("Output does not match expected: ('-' actual, '+' expected)\n%s", diff.Diff(actualStdOut, string(expected)))
 			}
 
@@ -519,10 +522,12 @@ func TestCmdBlocksFmtVerbsJson(t *testing.T) {
 			for _, block := range testcase.expectedBlocks {
 				data.BlockCount++
 				blockData := Block{
+					File:        testcase.sourcefile,
 					BlockNumber: data.BlockCount,
 					StartLine:   block.startLine,
 					EndLine:     block.endLine,
-					Text:        fmtverbs.Escape(block.text),
+					Text:        escapeVerbs(t, block.text),
+					Original:    block.text,
 				}
 				data.Blocks = append(data.Blocks, blockData)
 			}
@@ -534,7 +539,7 @@ func TestCmdBlocksFmtVerbsJson(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			err = findBlocksInFile(fs, log, testcase.sourcefile, false, false, true, true, nil, &outB, &errB)
+			err = findBlocks(fs, []string{testcase.sourcefile}, fileOptions{Options: terrafmt.Options{FmtCompat: true, Log: log}, Blocks: FlagsBlocks{JSON: true}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -542,7 +547,7 @@ func TestCmdBlocksFmtVerbsJson(t *testing.T) {
 				t.Fatalf("Got an error when none was expected: %v", err)
 			}
 
-			if !equivalentJSON([]byte(actualStdOut), expected) {
+			if !equivalentJSON(withoutPositions(t, actualStdOut), expected) {
 				t.Errorf("Output does not match expected: ('-' actual, '+' expected)\n%s", diff.Diff(actualStdOut, string(expected)))
 			}
 
@@ -580,3 +585,149 @@ func TestBlocksOutputJsonSerializesEmptyArray(t *testing.T) {
 		t.Errorf("Unexpected JSON output:\nexpected %s\ngot      %s", expected, string(actual))
 	}
 }
+
+// escapeVerbs returns block with its format verbs escaped as blocks --fmtcompat outputs it
+func escapeVerbs(t *testing.T, block string) string {
+	t.Helper()
+
+	escaped, err := fmtverbs.Escape(block)
+	if err != nil {
+		t.Fatalf("Error escaping format verbs: %v", err)
+	}
+
+	return escaped.Text
+}
+
+// withoutPositions returns the JSON blocks output with the language, columns, and byte offsets of its
+// blocks cleared, these are covered by TestCmdBlocksJsonPositions
+func withoutPositions(t *testing.T, output string) []byte {
+	t.Helper()
+
+	var data Output
+	if err := json.Unmarshal([]byte(output), &data); err != nil {
+		t.Fatalf("Error reading JSON output: %v", err)
+	}
+	for i := range data.Blocks {
+		data.Blocks[i].Language, data.Blocks[i].Func = "", ""
+		data.Blocks[i].TextStartLine, data.Blocks[i].TextEndLine = 0, 0
+		data.Blocks[i].TextStartColumn, data.Blocks[i].TextEndColumn = 0, 0
+		data.Blocks[i].TextStartOffset, data.Blocks[i].TextEndOffset = 0, 0
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("Error writing JSON output: %v", err)
+	}
+
+	return b
+}
+
+func TestCmdBlocksJsonPositions(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	files := map[string]string{
+		"docs/a.md": "# x\n\n```hcl\nlocals {}\n```\n",
+		"main.go":   "package main\n\nconst c = `\nlocals {\n}\n`\n",
+	}
+	for filename, content := range files {
+		if err := afero.WriteFile(fs, filename, []byte(content), 0o644); err != nil {
+			t.Fatalf("Error writing %q: %s", filename, err)
+		}
+	}
+
+	expected := []Block{
+		{File: "docs/a.md", Language: "hcl", BlockNumber: 1, StartLine: 3, EndLine: 5, TextStartLine: 4, TextStartColumn: 1, TextEndLine: 5, TextEndColumn: 1, TextStartOffset: 12, TextEndOffset: 22, Text: "locals {}\n", Original: "locals {}\n"},
+		{File: "main.go", BlockNumber: 1, StartLine: 3, EndLine: 6, TextStartLine: 3, TextStartColumn: 11, TextEndLine: 6, TextEndColumn: 2, TextStartOffset: 24, TextEndOffset: 38, Text: "locals {\n}\n", Original: "locals {\n}\n"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		var outB, errB strings.Builder
+		err := findBlocks(fs, []string{"docs/a.md", "main.go"}, fileOptions{Options: terrafmt.Options{Log: common.CreateLogger(&errB)}, Blocks: FlagsBlocks{JSON: true}}, &outB, &errB)
+		if err != nil {
+			t.Fatalf("Got an error when none was expected: %v", err)
+		}
+
+		var data Output
+		if err := json.Unmarshal([]byte(outB.String()), &data); err != nil {
+			t.Fatalf("Error reading JSON output: %v", err)
+		}
+		if data.BlockCount != len(expected) || !reflect.DeepEqual(data.Blocks, expected) {
+			t.Errorf("Unexpected blocks:\nexpected %+v\ngot      %+v", expected, data.Blocks)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		t.Parallel()
+
+		var outB, errB strings.Builder
+		err := findBlocks(fs, []string{"docs/a.md", "main.go"}, fileOptions{Options: terrafmt.Options{Log: common.CreateLogger(&errB)}, Blocks: FlagsBlocks{NDJSON: true}}, &outB, &errB)
+		if err != nil {
+			t.Fatalf("Got an error when none was expected: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(outB.String(), "\n"), "\n")
+		if len(lines) != len(expected) {
+			t.Fatalf("Expected %d lines, got %d:\n%s", len(expected), len(lines), outB.String())
+		}
+		for i, line := range lines {
+			var b Block
+			if err := json.Unmarshal([]byte(line), &b); err != nil {
+				t.Fatalf("Error reading JSON line %q: %v", line, err)
+			}
+			if b != expected[i] {
+				t.Errorf("Unexpected block on line %d:\nexpected %+v\ngot      %+v", i+1, expected[i], b)
+			}
+		}
+	})
+}
+
+func TestCmdBlocksMultipleFiles(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewReadOnlyFs(afero.NewOsFs())
+
+	var outB, errB strings.Builder
+	err := findBlocks(fs, []string{"testdata/no_diffs.md", "testdata/missing.md", "testdata/has_diffs.tf"}, fileOptions{Options: terrafmt.Options{Log: common.CreateLogger(&errB)}, Blocks: FlagsBlocks{JSON: true}}, &outB, &errB)
+	if err == nil || !strings.Contains(err.Error(), "missing.md") {
+		t.Errorf("Expected an error for the missing file, got %v", err)
+	}
+
+	// the files that could be read are still output
+	var data Output
+	if err := json.Unmarshal([]byte(outB.String()), &data); err != nil {
+		t.Fatalf("Error reading JSON output: %v", err)
+	}
+	files := map[string]int{}
+	for _, b := range data.Blocks {
+		files[b.File]++
+	}
+	if files["testdata/no_diffs.md"] == 0 || files["testdata/has_diffs.tf"] != 1 || data.BlockCount != len(data.Blocks) {
+		t.Errorf("Expected the blocks of both files, got %v", files)
+	}
+}
+
+func TestCmdBlocksMultipleFilesText(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewReadOnlyFs(afero.NewOsFs())
+
+	var outB, errB strings.Builder
+	err := findBlocks(fs, []string{"testdata/has_diffs.tf", "testdata/no_blocks.md"}, fileOptions{Options: terrafmt.Options{Log: common.CreateLogger(&errB)}, Verbose: true}, &outB, &errB)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	// with several files each block and summary names the file it is from
+	header := c.Sprintf("\n<white>#######</> <cyan>B1</><darkGray> @ testdata/has_diffs.tf #16</>\n")
+	if !strings.HasPrefix(outB.String(), header) {
+		t.Errorf("Expected the output to start with %q, got:\n%s", header, outB.String())
+	}
+	for _, filename := range []string{"testdata/has_diffs.tf", "testdata/no_blocks.md"} {
+		if summary := "Finished processing " + filename + ":"; !strings.Contains(errB.String(), summary) {
+			t.Errorf("Expected the summary %q, got:\n%s", summary, errB.String())
+		}
+	}
+}
@@ -3,15 +3,18 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go/ast"
-	"go/token"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	c "github.com/gookit/color"
 	"github.com/hashicorp/go-multierror"
@@ -19,9 +22,8 @@ import (
 	"github.com/katbyte/terrafmt/lib/blocks"
 	"github.com/katbyte/terrafmt/lib/common"
 	verbs "github.com/katbyte/terrafmt/lib/fmtverbs"
-	"github.com/katbyte/terrafmt/lib/format"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
 	"github.com/katbyte/terrafmt/lib/version"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -32,11 +34,20 @@ const (
 	ExitCodeMiscError           = 1
 	ExitCodeBlockParsingError   = 1 << 1
 	ExitCodeFormattingDiffError = 1 << 2
+	ExitCodeFormatVerbError     = 1 << 3
 )
 
+// ExitCodeError is returned by commands that finished without an error to report, but with a non
+// zero exit code, e.g. fmt finding a block it cannot parse.
+type ExitCodeError int
+
+func (e ExitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
 func Make() (*cobra.Command, error) {
 	root := &cobra.Command{
-		Use:           "terrafmt [fmt|diff|blocks]",
+		Use:           "terrafmt [fmt|diff|blocks|apply|replace|extract|inject|verbs|positional]",
 		Short:         "terrafmt is a small utility to format terraform blocks found in files.",
 		Long:          `A small utility that formats terraform blocks found in files. Primarily intended to help with terraform provider development.`,
 		Args:          cobra.RangeArgs(0, 0),
@@ -78,29 +89,29 @@ func Make() (*cobra.Command, error) {
 
 			fs := afero.NewOsFs()
 
-			filenames, err := allFiles(fs, path, f.Fmt.Pattern)
+			filenames, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
 			if err != nil {
 				return err
 			}
 
-			var errs *multierror.Error
-			exitCode := ExitCodeNoError
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
 
-			for _, filename := range filenames {
-				br, err := formatFile(fs, log, filename, f.FmtCompat, f.Fmt.FixFinishLines, f.Verbose, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
-				if err != nil {
-					errs = multierror.Append(errs, err)
+			exitCode, err := processFiles(ctx, filenames, f.Fmt.Parallelism, cmd.OutOrStdout(), cmd.ErrOrStderr(), func(filename string, stdout, stderr io.Writer) (int, error) {
+				res, err := formatFile(ctx, fs, filename, f.fileOptions(common.CreateLogger(stderr), cmd.InOrStdin()), stdout, stderr)
+				if res != nil && res.ErrorBlocks > 0 {
+					return ExitCodeBlockParsingError, err
 				}
 
-				if br.ErrorBlocks > 0 {
-					exitCode |= ExitCodeBlockParsingError
-				}
-			}
-			if errs != nil {
-				return errs
+				return ExitCodeNoError, err
+			})
+			if err != nil {
+				return err
 			}
 			if exitCode != ExitCodeNoError {
-				os.Exit(exitCode)
+				// the blocks that failed have been logged, usage would only bury them
+				cmd.SilenceUsage = true
+				return ExitCodeError(exitCode)
 			}
 
 			return nil
@@ -110,6 +121,8 @@ func Make() (*cobra.Command, error) {
 	root.AddCommand(fmtCmd)
 	fmtCmd.Flags().Bool("fix-finish-lines", false, "fix block finish lines by removing any leading spaces")
 	fmtCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *.markdown)")
+	addSelectFlags(fmtCmd)
+	fmtCmd.Flags().Int("parallelism", runtime.GOMAXPROCS(0), "number of files to process at the same time")
 
 	// options : only count, blocks diff/found, total lines diff, etc
 	diffCmd := &cobra.Command{
@@ -131,34 +144,78 @@ func Make() (*cobra.Command, error) {
 				return err
 			}
 
+			// a report replaces the diff, and is written once all of the files are done
+			writeReport, ok := reportWriters[f.Diff.Format]
+			if !ok && f.Diff.Format != ReportFormatText {
+				return fmt.Errorf("unknown format %q, expected one of: %s", f.Diff.Format, strings.Join(reportFormats(), ", "))
+			}
+			if writeReport != nil && f.Diff.Patch {
+				return fmt.Errorf("--patch can not be used with --format %s", f.Diff.Format)
+			}
+			var reportMu sync.Mutex
+			var reportFiles []reportFile
+
 			fs := afero.NewOsFs()
 
-			filenames, err := allFiles(fs, path, f.Fmt.Pattern)
+			filenames, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
 			if err != nil {
 				return err
 			}
 
-			var errs *multierror.Error
-			exitCode := ExitCodeNoError
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			exitCode, err := processFiles(ctx, filenames, f.Fmt.Parallelism, cmd.OutOrStdout(), cmd.ErrOrStderr(), func(filename string, stdout, stderr io.Writer) (int, error) {
+				if writeReport != nil {
+					stdout = io.Discard
+				}
+
+				res, err := diffFile(ctx, fs, filename, f.fileOptions(common.CreateLogger(stderr), cmd.InOrStdin()), stdout, stderr)
+
+				// a file that could not be read is in the report too, so CI shows what went wrong
+				if writeReport != nil && (err == nil || ctx.Err() == nil) {
+					rf := reportFile{FileResult: res, Err: err}
+					if err != nil {
+						rf.FileResult = &terrafmt.FileResult{Filename: filename}
+						if filename == "" {
+							rf.Filename = "stdin"
+						}
+					}
+
+					reportMu.Lock()
+					reportFiles = append(reportFiles, rf)
+					reportMu.Unlock()
+				}
 
-			for _, filename := range filenames {
-				br, fileDiff, err := diffFile(fs, log, filename, f.FmtCompat, f.Verbose, f.Quiet, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
 				if err != nil {
-					errs = multierror.Append(errs, err)
-					continue
+					return ExitCodeNoError, err
 				}
-				if br.ErrorBlocks > 0 {
+
+				exitCode := ExitCodeNoError
+				if res.ErrorBlocks > 0 {
 					exitCode |= ExitCodeBlockParsingError
 				}
-				if f.Check && fileDiff {
+				if f.Check && res.Changed() {
 					exitCode |= ExitCodeFormattingDiffError
 				}
+
+				return exitCode, nil
+			})
+
+			if writeReport != nil {
+				slices.SortFunc(reportFiles, func(a, b reportFile) int {
+					return strings.Compare(a.Filename, b.Filename)
+				})
+				if rerr := writeReport(cmd.OutOrStdout(), reportFiles); rerr != nil {
+					return fmt.Errorf("error writing %s report: %w", f.Diff.Format, rerr)
+				}
 			}
-			if errs != nil {
-				return errs
+
+			if err != nil {
+				return err
 			}
 			if exitCode != ExitCodeNoError {
-				os.Exit(exitCode)
+				return ExitCodeError(exitCode)
 			}
 
 			return nil
@@ -167,102 +224,345 @@ func Make() (*cobra.Command, error) {
 
 	root.AddCommand(diffCmd)
 	diffCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *.markdown)")
+	addSelectFlags(diffCmd)
+	diffCmd.Flags().Int("parallelism", runtime.GOMAXPROCS(0), "number of files to process at the same time")
+	diffCmd.Flags().String("format", ReportFormatText, "output format, one of: "+strings.Join(reportFormats(), ", "))
+	diffCmd.Flags().Bool("patch", false, "output a unified diff of each file that can be applied with git apply or patch -p1")
+	diffCmd.Flags().Int("context", terrafmt.DefaultPatchContext, "number of unchanged lines around each change of a --patch")
+	diffCmd.Flags().String("patch-root", "", "directory the paths of a --patch are relative to (default the working directory)")
 
 	// options
 	blocksCmd := &cobra.Command{
-		Use:   "blocks [file]",
-		Short: "extracts terraform blocks from a file ",
+		Use:   "blocks [path...]",
+		Short: "extracts terraform blocks from files, directories, or stdin",
 		// options: no header (######), format (json? xml? etc), only should block x?
-		Args: cobra.RangeArgs(0, 1),
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log := common.CreateLogger(cmd.ErrOrStderr())
-
-			filename := ""
-			if len(args) == 1 {
-				filename = args[0]
-			}
-			log.Debugf("terrafmt blocks %s", filename)
+			log.Debugf("terrafmt blocks %s", strings.Join(args, " "))
 
 			f, err := GetFlags()
 			if err != nil {
 				return err
 			}
 
-			if f.Blocks.ZeroTerminated && f.Blocks.JSON {
-				return errors.New("only one of zero-terminated or json can be specified")
+			outputs := 0
+			for _, set := range []bool{f.Blocks.ZeroTerminated, f.Blocks.JSON, f.Blocks.NDJSON} {
+				if set {
+					outputs++
+				}
+			}
+			if outputs > 1 {
+				return errors.New("only one of zero-terminated, json, or ndjson can be specified")
 			}
 			fs := afero.NewOsFs()
 
-			return findBlocksInFile(fs, log, filename, f.Verbose, f.Blocks.ZeroTerminated, f.Blocks.JSON, f.FmtCompat, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+			paths := args
+			if len(paths) == 0 {
+				paths = []string{""}
+			}
+			var filenames []string
+			for _, path := range paths {
+				pathFiles, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
+				if err != nil {
+					return err
+				}
+				filenames = append(filenames, pathFiles...)
+			}
+
+			return findBlocks(fs, filenames, f.fileOptions(log, cmd.InOrStdin()), cmd.OutOrStdout(), cmd.ErrOrStderr())
 		},
 	}
 	root.AddCommand(blocksCmd)
 	blocksCmd.Flags().BoolP("zero-terminated", "z", false, "outputs blocks separated by null separator")
 	blocksCmd.Flags().BoolP("json", "j", false, "outputs blocks in JSON format")
+	blocksCmd.Flags().Bool("ndjson", false, "outputs each block as a line of JSON as soon as it is found")
+	blocksCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *.markdown)")
+	addSelectFlags(blocksCmd)
 
 	root.AddCommand(&cobra.Command{
-		Use:   "version",
-		Short: "Print the version number of terrafmt",
-		Args:  cobra.NoArgs,
-		Run:   versionCmd,
+		Use:          "apply [file]",
+		Short:        "applies a unified diff or JSON block edits from a file or stdin to the blocks of the files they name",
+		Args:         cobra.RangeArgs(0, 1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+
+			filename := ""
+			if len(args) == 1 {
+				filename = args[0]
+			}
+			log.Debugf("terrafmt apply %s", filename)
+
+			f, err := GetFlags()
+			if err != nil {
+				return err
+			}
+
+			fs := afero.NewOsFs()
+
+			var input []byte
+			if filename == "" {
+				input, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				input, err = afero.ReadFile(fs, filename)
+			}
+			if err != nil {
+				return fmt.Errorf("error reading edits: %w", err)
+			}
+
+			edits, err := readEdits(fs, input)
+			if err != nil {
+				return err
+			}
+
+			return applyEdits(cmd.Context(), fs, log, edits, f.Verbose, cmd.ErrOrStderr())
+		},
 	})
 
-	if err := configureFlags(root); err != nil {
-		return nil, err
-	}
+	replaceCmd := &cobra.Command{
+		Use:          "replace <file>",
+		Short:        "replaces the block of a file selected with --block, --line, or --func with the text read from stdin",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+			log.Debugf("terrafmt replace %s", args[0])
 
-	return root, nil
-}
+			f, err := GetFlags()
+			if err != nil {
+				return err
+			}
+
+			text, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("error reading the new block: %w", err)
+			}
+
+			res, err := terrafmt.ReplaceBlock(cmd.Context(), afero.NewOsFs(), args[0], f.Select.selection(), string(text), terrafmt.Options{Log: log})
+			if err != nil {
+				return err
+			}
 
-func allFiles(fs afero.Fs, path, pattern string) ([]string, error) {
-	if path == "" {
-		return []string{""}, nil
+			if f.Verbose {
+				fmt.Fprint(cmd.ErrOrStderr(), c.Sprintf("<lightMagenta>%s</>: replaced <yellow>%d</> block!\n", res.Filename, res.ChangedBlocks))
+			}
+
+			return nil
+		},
 	}
+	root.AddCommand(replaceCmd)
+	addSelectFlags(replaceCmd)
 
-	info, err := fs.Stat(path)
-	if err != nil {
-		return nil, fmt.Errorf("error reading path (%s): %w", path, err)
+	extractCmd := &cobra.Command{
+		Use:          "extract [path...]",
+		Short:        "writes each terraform block of files or directories to its own .tf file, with a manifest to inject them back",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+			log.Debugf("terrafmt extract %s", strings.Join(args, " "))
+
+			f, err := GetFlags()
+			if err != nil {
+				return err
+			}
+			if f.Extract.Out == "" {
+				return errors.New("an --out directory is required")
+			}
+
+			fs := afero.NewOsFs()
+
+			var filenames []string
+			for _, path := range args {
+				pathFiles, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
+				if err != nil {
+					return err
+				}
+				filenames = append(filenames, pathFiles...)
+			}
+
+			manifest, err := terrafmt.Extract(cmd.Context(), fs, filenames, f.Extract.Out, terrafmt.Options{
+				FmtCompat: f.FmtCompat,
+				Log:       log,
+			})
+			if manifest != nil && f.Verbose {
+				fmt.Fprint(cmd.ErrOrStderr(), c.Sprintf("extracted <yellow>%d</> blocks to <lightMagenta>%s</>\n", len(manifest.Blocks), filepath.Join(f.Extract.Out, terrafmt.ManifestName)))
+			}
+
+			return err
+		},
 	}
+	root.AddCommand(extractCmd)
+	extractCmd.Flags().StringP("out", "o", "", "directory to write the blocks and their manifest to")
+	extractCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *.markdown)")
+
+	injectCmd := &cobra.Command{
+		Use:          "inject",
+		Short:        "puts the blocks written by extract back into the files they came from",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+			log.Debugf("terrafmt inject")
+
+			f, err := GetFlags()
+			if err != nil {
+				return err
+			}
+			if f.Extract.Manifest == "" {
+				return errors.New("a --manifest is required")
+			}
+
+			results, err := terrafmt.Inject(cmd.Context(), afero.NewOsFs(), f.Extract.Manifest, terrafmt.Options{Log: log})
+			if f.Verbose {
+				for _, res := range results {
+					fmt.Fprint(cmd.ErrOrStderr(), c.Sprintf("<lightMagenta>%s</>: injected <yellow>%d</>/<yellow>%d</> blocks!\n", res.Filename, res.ChangedBlocks, len(res.Blocks)))
+				}
+			}
 
-	if !info.IsDir() {
-		return []string{path}, nil
+			return err
+		},
 	}
+	root.AddCommand(injectCmd)
+	injectCmd.Flags().StringP("manifest", "m", "", "the manifest written by extract, "+terrafmt.ManifestName+" in its --out directory")
 
-	var filenames []string
+	verbsCmd := &cobra.Command{
+		Use:          "verbs [path...]",
+		Short:        "checks the format verbs of go blocks against the arguments of the fmt.Sprintf or fmt.Errorf call they are the format of",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+			log.Debugf("terrafmt verbs %s", strings.Join(args, " "))
 
-	err = afero.Walk(fs, path,
-		func(path string, info os.FileInfo, err error) error {
+			f, err := GetFlags()
 			if err != nil {
 				return err
 			}
 
-			if info.IsDir() {
-				return nil
+			fs := afero.NewOsFs()
+
+			var filenames []string
+			for _, path := range args {
+				pathFiles, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
+				if err != nil {
+					return err
+				}
+				filenames = append(filenames, pathFiles...)
 			}
 
-			if pattern == "" {
-				filenames = append(filenames, path)
+			exitCode := ExitCodeNoError
+			var errs *multierror.Error
+			for _, filename := range filenames {
+				res, err := terrafmt.CheckVerbs(cmd.Context(), fs, filename, terrafmt.Options{
+					Select: f.Select.selection(),
+					Log:    log,
+				})
+				if err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("%s: %w", filename, err))
+
+					continue
+				}
+
+				for _, d := range res.Diagnostics {
+					fmt.Fprint(cmd.OutOrStdout(), d.Annotated())
+					if d.Severity == "error" {
+						exitCode |= ExitCodeFormatVerbError
+					}
+				}
+
+				if f.Verbose {
+					fmt.Fprint(cmd.ErrOrStderr(), c.Sprintf("<lightMagenta>%s</>: checked <yellow>%d</> blocks in <yellow>%d</> calls, found <yellow>%d</> problems\n", res.Filename, res.Blocks, res.Calls, len(res.Diagnostics)))
+				}
+			}
 
-				return nil
+			if err := errs.ErrorOrNil(); err != nil {
+				return err
 			}
+			if exitCode != ExitCodeNoError {
+				return ExitCodeError(exitCode)
+			}
+
+			return nil
+		},
+	}
+	root.AddCommand(verbsCmd)
+	verbsCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *_test.go)")
+	addSelectFlags(verbsCmd)
+
+	positionalCmd := &cobra.Command{
+		Use:          "positional [path...]",
+		Short:        "rewrites the format verbs of go blocks to explicit argument indexes (%[1]s), merging the arguments their fmt.Sprintf call repeats",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := common.CreateLogger(cmd.ErrOrStderr())
+			log.Debugf("terrafmt positional %s", strings.Join(args, " "))
 
-			matched, err := filepath.Match(pattern, filepath.Base(path))
+			f, err := GetFlags()
 			if err != nil {
 				return err
 			}
 
-			if matched {
-				filenames = append(filenames, path)
+			fs := afero.NewOsFs()
+
+			var filenames []string
+			for _, path := range args {
+				pathFiles, err := terrafmt.Files(fs, path, f.Fmt.Pattern)
+				if err != nil {
+					return err
+				}
+				filenames = append(filenames, pathFiles...)
+			}
+
+			exitCode := ExitCodeNoError
+			var errs *multierror.Error
+			for _, filename := range filenames {
+				res, err := terrafmt.PositionalVerbs(cmd.Context(), fs, filename, terrafmt.Options{
+					Select: f.Select.selection(),
+					Log:    log,
+				})
+				if err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("%s: %w", filename, err))
+
+					continue
+				}
+
+				if res.ErrorBlocks > 0 {
+					exitCode |= ExitCodeFormatVerbError
+				}
+				if f.Verbose {
+					fmt.Fprint(cmd.ErrOrStderr(), c.Sprintf("<lightMagenta>%s</>: rewrote <yellow>%d</>/<yellow>%d</> blocks!\n", res.Filename, res.ChangedBlocks, res.BlockCount))
+				}
+			}
+
+			if err := errs.ErrorOrNil(); err != nil {
+				return err
+			}
+			if exitCode != ExitCodeNoError {
+				return ExitCodeError(exitCode)
 			}
 
 			return nil
 		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error walking path (%s): %w", path, err)
+	}
+	root.AddCommand(positionalCmd)
+	positionalCmd.Flags().StringP("pattern", "p", "", "glob pattern to match with each file name (e.g. *_test.go)")
+	addSelectFlags(positionalCmd)
+
+	root.AddCommand(&cobra.Command{
+		Use:   "version",
+		Short: "Print the version number of terrafmt",
+		Args:  cobra.NoArgs,
+		Run:   versionCmd,
+	})
+
+	if err := configureFlags(root); err != nil {
+		return nil, err
 	}
 
-	return filenames, nil
+	return root, nil
 }
 
 func versionCmd(_ *cobra.Command, _ []string) {
@@ -270,11 +570,16 @@ func versionCmd(_ *cobra.Command, _ []string) {
 }
 
 type textBlockWriter struct {
-	writer io.Writer
+	writer   io.Writer
+	showFile bool // the block numbers of several files are told apart by their file
 }
 
-func (w textBlockWriter) Write(index, _, endLine int, text string) {
-	fmt.Fprint(w.writer, c.Sprintf("\n<white>#######</> <cyan>B%d</><darkGray> @ #%d</>\n", index, endLine))
+func (w textBlockWriter) Write(b blocks.Block, _, endLine int, text string) {
+	if w.showFile {
+		fmt.Fprint(w.writer, c.Sprintf("\n<white>#######</> <cyan>B%d</><darkGray> @ %s #%d</>\n", b.Number, b.File, endLine))
+	} else {
+		fmt.Fprint(w.writer, c.Sprintf("\n<white>#######</> <cyan>B%d</><darkGray> @ #%d</>\n", b.Number, endLine))
+	}
 	fmt.Fprint(w.writer, text)
 }
 
@@ -284,18 +589,51 @@ type zeroTerminatedBlockWriter struct {
 	writer io.Writer
 }
 
-func (w zeroTerminatedBlockWriter) Write(_, _, _ int, text string) {
+func (w zeroTerminatedBlockWriter) Write(_ blocks.Block, _, _ int, text string) {
 	fmt.Fprint(w.writer, text)
 	fmt.Fprint(w.writer, "\x00")
 }
 
 func (w zeroTerminatedBlockWriter) Close() error { return nil }
 
+// Block is a block of the JSON output. StartLine and EndLine are the lines the block is found between
+// (for text documents the lines opening and closing it) as apply checks them, while the Text fields
+// are the span of its text in the file (for go the string literal): lines and columns are 1 based and
+// the end is just after it.
 type Block struct {
-	BlockNumber int    `json:"block_number"`
-	StartLine   int    `json:"start_line"`
-	EndLine     int    `json:"end_line"`
-	Text        string `json:"text"`
+	File            string `json:"file"`
+	Language        string `json:"language,omitempty"`
+	Func            string `json:"func,omitempty"`
+	BlockNumber     int    `json:"block_number"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	TextStartLine   int    `json:"text_start_line"`
+	TextStartColumn int    `json:"text_start_column"`
+	TextEndLine     int    `json:"text_end_line"`
+	TextEndColumn   int    `json:"text_end_column"`
+	TextStartOffset int    `json:"text_start_offset"`
+	TextEndOffset   int    `json:"text_end_offset"`
+	Text            string `json:"text"`
+	Original        string `json:"original"` // the block's text in the file, Text is escaped by --fmtcompat
+}
+
+func newBlock(b blocks.Block, startLine, endLine int, text string) Block {
+	return Block{
+		File:            b.File,
+		Language:        b.Language,
+		Func:            b.Func,
+		BlockNumber:     b.Number,
+		StartLine:       startLine,
+		EndLine:         endLine,
+		TextStartLine:   b.StartLine,
+		TextStartColumn: b.StartColumn,
+		TextEndLine:     b.EndLine,
+		TextEndColumn:   b.EndColumn,
+		TextStartOffset: b.StartOffset,
+		TextEndOffset:   b.EndOffset,
+		Text:            text,
+		Original:        b.Text,
+	}
 }
 
 type Output struct {
@@ -320,14 +658,9 @@ type jsonBlockWriter struct {
 	data   Output
 }
 
-func (w *jsonBlockWriter) Write(index, startLine, endLine int, text string) {
+func (w *jsonBlockWriter) Write(b blocks.Block, startLine, endLine int, text string) {
 	w.data.BlockCount++
-	w.data.Blocks = append(w.data.Blocks, Block{
-		BlockNumber: index,
-		StartLine:   startLine,
-		EndLine:     endLine,
-		Text:        text,
-	})
+	w.data.Blocks = append(w.data.Blocks, newBlock(b, startLine, endLine, text))
 }
 
 func (w *jsonBlockWriter) Close() error {
@@ -335,195 +668,189 @@ func (w *jsonBlockWriter) Close() error {
 	return encoder.Encode(w.data)
 }
 
-func findBlocksInFile(fs afero.Fs, log *logrus.Logger, filename string, verbose, zeroTerminated, jsonOutput, fmtverbs bool, stdin io.Reader, stdout, stderr io.Writer) error {
+// ndjsonBlockWriter writes each block as a line of JSON straight away, so nothing is held in memory
+type ndjsonBlockWriter struct {
+	encoder *json.Encoder
+	err     error
+}
+
+func (w *ndjsonBlockWriter) Write(b blocks.Block, startLine, endLine int, text string) {
+	if w.err == nil {
+		w.err = w.encoder.Encode(newBlock(b, startLine, endLine, text))
+	}
+}
+
+func (w *ndjsonBlockWriter) Close() error { return w.err }
+
+// fileOptions configures how the fmt, diff, and blocks commands process and report on each file
+type fileOptions struct {
+	terrafmt.Options
+
+	Verbose      bool        // report the lines and blocks of each file on stderr
+	Quiet        bool        // diff only, list the blocks needing formatting without their diff
+	Patch        bool        // diff only, write a unified diff of the file instead
+	PatchRoot    string      // diff only, the directory the paths of a patch are relative to
+	PatchContext int         // diff only, the lines of context around each change of a patch
+	Blocks       FlagsBlocks // blocks only, how the blocks are written
+}
+
+func findBlocks(fs afero.Fs, filenames []string, opts fileOptions, stdout, stderr io.Writer) error {
 	var blockWriter blocks.BlockWriter
 
-	//nolint:gocritic // ifElseChain: a switch here would not be any clearer
-	if zeroTerminated {
+	switch {
+	case opts.Blocks.ZeroTerminated:
 		blockWriter = zeroTerminatedBlockWriter{
 			writer: stdout,
 		}
-	} else if jsonOutput {
+	case opts.Blocks.JSON:
 		blockWriter = &jsonBlockWriter{
 			writer: stdout,
 		}
-	} else {
+	case opts.Blocks.NDJSON:
+		blockWriter = &ndjsonBlockWriter{
+			encoder: json.NewEncoder(stdout),
+		}
+	default:
 		blockWriter = textBlockWriter{
-			writer: stdout,
+			writer:   stdout,
+			showFile: len(filenames) > 1,
+		}
+	}
+
+	// a file that can't be read is reported once the rest have been, so the output is still complete
+	var errs *multierror.Error
+	for _, filename := range filenames {
+		if err := findBlocksInFile(fs, blockWriter, filename, len(filenames) > 1, opts, stderr); err != nil {
+			errs = multierror.Append(errs, err)
 		}
 	}
 
+	if err := blockWriter.Close(); err != nil {
+		return fmt.Errorf("error writing blocks output: %w", err)
+	}
+
+	return errs.ErrorOrNil()
+}
+
+func findBlocksInFile(fs afero.Fs, blockWriter blocks.BlockWriter, filename string, showFile bool, opts fileOptions, stderr io.Writer) error {
 	br := blocks.Reader{
-		Log:         log,
+		Log:         opts.Log,
 		ReadOnly:    true,
+		Select:      opts.Select,
 		LineRead:    blocks.ReaderIgnore,
 		BlockWriter: blockWriter,
 		BlockRead: func(br *blocks.Reader, _ int, b string, _ bool) error {
-			if fmtverbs {
-				b = verbs.Escape(b)
+			if opts.FmtCompat {
+				escaped, err := verbs.Escape(b)
+				if err != nil {
+					return err
+				}
+				b = escaped.Text
 			}
 
-			br.BlockWriter.Write(br.BlockCount, br.LineCount-br.BlockCurrentLine, br.LineCount, b)
+			br.BlockWriter.Write(br.CurrentBlock(), br.LineCount-br.BlockCurrentLine, br.LineCount, b)
 
 			return nil
 		},
 	}
 
-	err := br.DoTheThing(fs, filename, stdin, stdout)
-	if err != nil {
+	if err := br.DoTheThing(fs, filename, opts.Stdin, io.Discard); err != nil {
 		return err
 	}
 
-	if err = blockWriter.Close(); err != nil {
-		return fmt.Errorf("error writing blocks output: %w", err)
-	}
-
-	if verbose {
-		fmt.Fprint(stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
+	if opts.Verbose {
+		if showFile {
+			fmt.Fprint(stderr, c.Sprintf("\nFinished processing %s: <cyan>%d</> lines <yellow>%d</> blocks!\n", filename, br.LineCount, br.BlockCount))
+		} else {
+			fmt.Fprint(stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
+		}
 	}
 
 	return nil
 }
 
-func diffFile(fs afero.Fs, log *logrus.Logger, filename string, fmtverbs, verbose, quiet bool, stdin io.Reader, stdout, stderr io.Writer) (*blocks.Reader, bool, error) {
-	blocksWithDiff := 0
-	br := blocks.Reader{
-		Log:      log,
-		ReadOnly: true,
-		LineRead: blocks.ReaderPassthrough,
-		BlockRead: func(br *blocks.Reader, _ int, b string, preserveIndent bool) error {
-			var fb string
-			var err error
-			if fmtverbs {
-				fb, err = format.FmtVerbBlock(log, b, filename)
-			} else {
-				fb, err = format.Block(log, b, filename)
-			}
-			if err != nil {
-				return err
-			}
-
-			if preserveIndent {
-				fb = indentToOriginalLevel(fb, b)
-			}
-
-			if fb == b {
-				return nil
-			}
-			blocksWithDiff++
-
-			outW := stdout
+func diffFile(ctx context.Context, fs afero.Fs, filename string, opts fileOptions, stdout, stderr io.Writer) (*terrafmt.FileResult, error) {
+	res, err := terrafmt.DiffFile(ctx, fs, filename, opts.Options)
+	if err != nil {
+		return nil, err
+	}
 
-			fmt.Fprint(outW, c.Sprintf("<lightMagenta>%s</><darkGray>:</><magenta>%d</>\n", br.FileName, br.LineCount-br.BlockCurrentLine))
+	if opts.Patch {
+		p, err := res.Patch(opts.PatchContext, opts.PatchRoot)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := stdout.Write(p); err != nil {
+			return nil, err
+		}
+	}
 
-			if !quiet {
-				d := diff.LineDiff(b, fb)
-				scanner := bufio.NewScanner(strings.NewReader(d))
-				for scanner.Scan() {
-					l := scanner.Text()
+	for _, b := range res.Blocks {
+		if opts.Patch || b.Status != terrafmt.BlockNeedsFormatting {
+			continue
+		}
 
-					//nolint:gocritic // ifElseChain: a switch here would not be any clearer
-					if strings.HasPrefix(l, "+") {
-						fmt.Fprint(outW, c.Sprintf("<green>%s</>\n", l))
-					} else if strings.HasPrefix(l, "-") {
-						fmt.Fprint(outW, c.Sprintf("<red>%s</>\n", l))
-					} else {
-						fmt.Fprint(outW, l+"\n")
-					}
+		fmt.Fprint(stdout, c.Sprintf("<lightMagenta>%s</><darkGray>:</><magenta>%d</>\n", res.Filename, b.StartLine))
+
+		if !opts.Quiet {
+			d := diff.LineDiff(b.Original, b.Formatted)
+			scanner := bufio.NewScanner(strings.NewReader(d))
+			for scanner.Scan() {
+				l := scanner.Text()
+
+				//nolint:gocritic // ifElseChain: a switch here would not be any clearer
+				if strings.HasPrefix(l, "+") {
+					fmt.Fprint(stdout, c.Sprintf("<green>%s</>\n", l))
+				} else if strings.HasPrefix(l, "-") {
+					fmt.Fprint(stdout, c.Sprintf("<red>%s</>\n", l))
+				} else {
+					fmt.Fprint(stdout, l+"\n")
 				}
 			}
-
-			return nil
-		},
-	}
-
-	err := br.DoTheThing(fs, filename, stdin, stdout)
-	if err != nil {
-		return nil, false, err
+		}
 	}
 
-	hasDiff := (blocksWithDiff > 0)
+	writeDiagnostics(stderr, res)
 
 	fc := "magenta"
-	if hasDiff {
+	if res.Changed() {
 		fc = "lightMagenta"
 	}
 
-	if verbose {
-		fmt.Fprint(stderr, c.Sprintf("<%s>%s</>: <cyan>%d</> lines & <yellow>%d</>/<yellow>%d</> blocks need formatting.\n", fc, br.FileName, br.LineCount, blocksWithDiff, br.BlockCount))
+	if opts.Verbose {
+		fmt.Fprint(stderr, c.Sprintf("<%s>%s</>: <cyan>%d</> lines & <yellow>%d</>/<yellow>%d</> blocks need formatting.\n", fc, res.Filename, res.Lines, res.ChangedBlocks, res.BlockCount))
 	}
 
-	return &br, hasDiff, nil
+	return res, nil
 }
 
-func formatFile(fs afero.Fs, log *logrus.Logger, filename string, fmtverbs, fixFinishLines, verbose bool, stdin io.Reader, stdout, stderr io.Writer) (*blocks.Reader, error) {
-	blocksFormatted := 0
-
-	br := blocks.Reader{
-		Log:      log,
-		LineRead: blocks.ReaderPassthrough,
-		BlockRead: func(br *blocks.Reader, _ int, b string, preserveIndent bool) error {
-			var fb string
-			var err error
-			if fmtverbs {
-				fb, err = format.FmtVerbBlock(log, b, filename)
-			} else {
-				fb, err = format.Block(log, b, filename)
-			}
-			if err != nil {
-				return err
-			}
-
-			if preserveIndent {
-				fb = indentToOriginalLevel(fb, b)
-			}
-
-			hasChange := fb != b
-
-			if br.CurrentNodeCursor != nil {
-				fb = strings.TrimSuffix(fb, "\n")
-
-				if br.FixFinishLines {
-					trimmed := strings.TrimRight(br.CurrentNodeTrailingPadding, " \t")
-					if trimmed != br.CurrentNodeTrailingPadding {
-						br.CurrentNodeTrailingPadding = trimmed
-						hasChange = true
-					}
-				}
-
-				if hasChange {
-					br.CurrentNodeCursor.Replace(&ast.BasicLit{
-						Kind: token.STRING,
-						Value: br.CurrentNodeQuoteChar +
-							br.CurrentNodeLeadingPadding +
-							fb +
-							br.CurrentNodeTrailingPadding +
-							br.CurrentNodeQuoteChar,
-					})
-					blocksFormatted++
-				}
-			} else {
-				_, err = br.Writer.Write([]byte(fb))
-
-				if err == nil && hasChange {
-					blocksFormatted++
-				}
-			}
-
-			return err
-		},
-		FixFinishLines: fixFinishLines,
+func formatFile(ctx context.Context, fs afero.Fs, filename string, opts fileOptions, stdout, stderr io.Writer) (*terrafmt.FileResult, error) {
+	opts.Stdout = stdout
+	res, err := terrafmt.FormatFile(ctx, fs, filename, opts.Options)
+	if res == nil {
+		return nil, err
 	}
-	err := br.DoTheThing(fs, filename, stdin, stdout)
+
+	writeDiagnostics(stderr, res)
 
 	fc := "magenta"
-	if blocksFormatted > 0 {
+	if res.Changed() {
 		fc = "lightMagenta"
 	}
 
-	if verbose {
-		fmt.Fprint(stderr, c.Sprintf("<%s>%s</>: <cyan>%d</> lines & formatted <yellow>%d</>/<yellow>%d</> blocks!\n", fc, br.FileName, br.LineCount, blocksFormatted, br.BlockCount))
+	if opts.Verbose {
+		fmt.Fprint(stderr, c.Sprintf("<%s>%s</>: <cyan>%d</> lines & formatted <yellow>%d</>/<yellow>%d</> blocks!\n", fc, res.Filename, res.Lines, res.ChangedBlocks, res.BlockCount))
 	}
 
-	return &br, err
+	return res, err
+}
+
+// writeDiagnostics writes where in the file each block that could not be parsed went wrong, compiler
+// style so editors can jump to it
+func writeDiagnostics(w io.Writer, res *terrafmt.FileResult) {
+	for _, b := range res.Blocks {
+		for _, d := range b.Diagnostics {
+			fmt.Fprint(w, d.Annotated())
+		}
+	}
 }
@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
 
 	c "github.com/gookit/color"
 	"github.com/katbyte/terrafmt/lib/common"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
 	"github.com/kylelemons/godebug/diff"
 	"github.com/spf13/afero"
 )
@@ -44,11 +46,11 @@ var diffTestcases = []struct {
 		fmtcompat:  false,
 		noDiff:     true,
 		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: testdata/fmt_compat.go:4,3-4:",
-			"block 3 @ %s:30 failed to process with: failed to parse hcl: testdata/fmt_compat.go:4,3-4:",
-			"block 4 @ %s:44 failed to process with: failed to parse hcl: testdata/fmt_compat.go:3,3-4:",
-			"block 5 @ %s:53 failed to process with: failed to parse hcl: testdata/fmt_compat.go:2,26-27:",
-			"block 6 @ %s:67 failed to process with: failed to parse hcl: testdata/fmt_compat.go:1,38-39:",
+			"block 1 @ %[1]s:8 failed to process with: failed to parse hcl: %[1]s:12:3:",
+			"block 3 @ %[1]s:30 failed to process with: failed to parse hcl: %[1]s:34:3:",
+			"block 4 @ %[1]s:44 failed to process with: failed to parse hcl: %[1]s:47:3:",
+			"block 5 @ %[1]s:53 failed to process with: failed to parse hcl: %[1]s:55:26:",
+			"block 6 @ %[1]s:67 failed to process with: failed to parse hcl: %[1]s:68:38:",
 		},
 		lineCount:       76,
 		totalBlockCount: 6,
@@ -67,7 +69,7 @@ var diffTestcases = []struct {
 		sourcefile: "testdata/bad_terraform.go",
 		resultfile: "testdata/bad_terraform_diff.go.txt",
 		errMsg: []string{
-			"block 2 @ %s:16 failed to process with: failed to parse hcl: testdata/bad_terraform.go:1,49-50: Unclosed configuration block; There is no closing brace for this block before the end of the file. This may be caused by incorrect brace nesting elsewhere in this file.\\nresource \\",
+			"block 2 @ %[1]s:16 failed to process with: failed to parse hcl: %[1]s:17:49: Unclosed configuration block; There is no closing brace for this block before the end of the file. This may be caused by incorrect brace nesting elsewhere in this file.",
 		},
 		lineCount:             20,
 		unformattedBlockCount: 1,
@@ -78,22 +80,19 @@ var diffTestcases = []struct {
 		sourcefile: "testdata/unsupported_fmt.go",
 		noDiff:     true,
 		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: testdata/unsupported_fmt.go:5,5-6:",
+			"block 1 @ %[1]s:8 failed to process with: failed to parse hcl: %[1]s:13:5:",
 		},
 		lineCount:             21,
 		unformattedBlockCount: 0,
 		totalBlockCount:       1,
 	},
 	{
-		name:       "Go unsupported format verbs --fmtcompat",
-		sourcefile: "testdata/unsupported_fmt.go",
-		noDiff:     true,
-		fmtcompat:  true,
-		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: testdata/unsupported_fmt.go:6,17-18:",
-		},
+		name:                  "Go unsupported format verbs --fmtcompat",
+		sourcefile:            "testdata/unsupported_fmt.go",
+		resultfile:            "testdata/unsupported_fmt_diff_fmtcompat.go.txt",
+		fmtcompat:             true,
 		lineCount:             21,
-		unformattedBlockCount: 0,
+		unformattedBlockCount: 1,
 		totalBlockCount:       1,
 	},
 	{
@@ -115,9 +114,24 @@ var diffTestcases = []struct {
 		sourcefile:            "testdata/has_diffs.rst",
 		resultfile:            "testdata/has_diffs_diff.rst.txt",
 		lineCount:             25,
-		unformattedBlockCount: 2,
+		unformattedBlockCount: 1,
 		totalBlockCount:       2,
 	},
+	{
+		name:                  "Terraform file formatting",
+		sourcefile:            "testdata/has_diffs.tf",
+		resultfile:            "testdata/has_diffs_diff.tf.txt",
+		lineCount:             16,
+		unformattedBlockCount: 1,
+		totalBlockCount:       1,
+	},
+	{
+		name:            "Terraform variables file no change",
+		sourcefile:      "testdata/no_diffs.tfvars",
+		noDiff:          true,
+		lineCount:       5,
+		totalBlockCount: 1,
+	},
 	{
 		name:                  "Markdown formatting",
 		sourcefile:            "testdata/has_diffs.md",
@@ -164,7 +178,7 @@ func TestCmdDiffDefault(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			br, hasDiff, err := diffFile(fs, log, testcase.sourcefile, testcase.fmtcompat, false, false, nil, &outB, &errB)
+			res, err := diffFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, Log: log}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -172,15 +186,15 @@ func TestCmdDiffDefault(t *testing.T) {
 				t.Fatalf("Got an error when none was expected: %v", err)
 			}
 
-			actualNoDiff := !hasDiff
+			actualNoDiff := !res.Changed()
 			if testcase.noDiff && !actualNoDiff {
 				t.Errorf("Expected no diff, but got one")
 			} else if !testcase.noDiff && actualNoDiff {
 				t.Errorf("Expected diff, but did not get one")
 			}
 
-			if len(testcase.errMsg) != br.ErrorBlocks {
-				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), br.ErrorBlocks)
+			if len(testcase.errMsg) != res.ErrorBlocks {
+				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), res.ErrorBlocks)
 			}
 
 			if actualStdOut != expected {
@@ -208,7 +222,7 @@ func TestCmdDiffVerbose(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			_, _, err := diffFile(fs, log, testcase.sourcefile, testcase.fmtcompat, true, false, nil, &outB, &errB)
+			_, err := diffFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, Log: log}, Verbose: true}, &outB, &errB)
 			actualStdErr := errB.String()
 
 			if err != nil {
@@ -237,3 +251,41 @@ func TestCmdDiffVerbose(t *testing.T) {
 		})
 	}
 }
+
+func TestCmdDiffPatch(t *testing.T) {
+	t.Parallel()
+
+	for _, testcase := range diffTestcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := afero.NewReadOnlyFs(afero.NewOsFs())
+
+			var outB strings.Builder
+			var errB strings.Builder
+			log := common.CreateLogger(&errB)
+			_, err := diffFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, Log: log}, Patch: true, PatchContext: 3}, &outB, &errB)
+			actualStdOut := outB.String()
+
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			if testcase.noDiff {
+				if actualStdOut != "" {
+					t.Errorf("Expected no patch, got:\n%s", actualStdOut)
+				}
+
+				return
+			}
+
+			expectedHeader := fmt.Sprintf("--- a/%[1]s\n+++ b/%[1]s\n@@ -", testcase.sourcefile)
+			if !strings.HasPrefix(actualStdOut, expectedHeader) {
+				t.Errorf("Expected the patch to start with %q, got:\n%s", expectedHeader, actualStdOut)
+			}
+			if strings.Contains(actualStdOut, "\x1b[") {
+				t.Errorf("Expected the patch to be uncoloured, got:\n%s", actualStdOut)
+			}
+		})
+	}
+}
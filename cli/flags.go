@@ -3,8 +3,12 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -17,25 +21,83 @@ type FlagData struct {
 	Quiet      bool `mapstructure:"quiet"`
 	Uncoloured bool `mapstructure:"uncoloured"`
 
-	Fmt    FlagsFmt    `mapstructure:",squash"`
-	Blocks FlagsBlocks `mapstructure:",squash"`
+	Fmt     FlagsFmt     `mapstructure:",squash"`
+	Diff    FlagsDiff    `mapstructure:",squash"`
+	Blocks  FlagsBlocks  `mapstructure:",squash"`
+	Select  FlagsSelect  `mapstructure:",squash"`
+	Extract FlagsExtract `mapstructure:",squash"`
 }
 
 // FlagsFmt holds the flags shared by the fmt and diff commands.
 type FlagsFmt struct {
 	Pattern        string `mapstructure:"pattern"`
 	FixFinishLines bool   `mapstructure:"fix-finish-lines"`
+	Parallelism    int    `mapstructure:"parallelism"`
+}
+
+// FlagsDiff holds the flags for the diff command.
+type FlagsDiff struct {
+	Format    string `mapstructure:"format"`
+	Patch     bool   `mapstructure:"patch"`
+	PatchRoot string `mapstructure:"patch-root"`
+	Context   int    `mapstructure:"context"`
 }
 
 // FlagsBlocks holds the flags for the blocks command.
 type FlagsBlocks struct {
 	ZeroTerminated bool `mapstructure:"zero-terminated"`
 	JSON           bool `mapstructure:"json"`
+	NDJSON         bool `mapstructure:"ndjson"`
+}
+
+// FlagsExtract holds the flags for the extract and inject commands.
+type FlagsExtract struct {
+	Out      string `mapstructure:"out"`
+	Manifest string `mapstructure:"manifest"`
+}
+
+// FlagsSelect holds the flags picking the blocks the fmt, diff, and blocks commands process.
+type FlagsSelect struct {
+	Block int    `mapstructure:"block"`
+	Line  int    `mapstructure:"line"`
+	Func  string `mapstructure:"func"`
+}
+
+// fileOptions returns the options for processing each file the flags describe, logging to log and
+// reading stdin for the empty filename
+func (f FlagData) fileOptions(log *logrus.Logger, stdin io.Reader) fileOptions {
+	return fileOptions{
+		Options: terrafmt.Options{
+			FmtCompat:      f.FmtCompat,
+			FixFinishLines: f.Fmt.FixFinishLines,
+			Select:         f.Select.selection(),
+			Log:            log,
+			Stdin:          stdin,
+		},
+		Verbose:      f.Verbose,
+		Quiet:        f.Quiet,
+		Patch:        f.Diff.Patch,
+		PatchRoot:    f.Diff.PatchRoot,
+		PatchContext: f.Diff.Context,
+		Blocks:       f.Blocks,
+	}
+}
+
+func (f FlagsSelect) selection() blocks.Selection {
+	return blocks.Selection{Number: f.Block, Line: f.Line, Func: f.Func}
+}
+
+// addSelectFlags adds the flags of FlagsSelect to cmd.
+func addSelectFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("block", 0, "only process the block with this number")
+	cmd.Flags().Int("line", 0, "only process the block on this line")
+	cmd.Flags().String("func", "", "only process the blocks in this go function (Type.Method for a method)")
 }
 
 // flagEnvMap is the full set of viper-managed flags and the env var each one can be
-// set with ("" = flag only: zero-terminated and json select per-invocation output
-// framing for scripts, an env var would silently corrupt whatever is parsing the output).
+// set with ("" = flag only: zero-terminated, json, ndjson, format, patch, patch-root, and context select
+// per-invocation output framing for scripts, an env var would silently corrupt whatever is parsing
+// the output, and block, line, func, out, and manifest only make sense for a single run).
 var flagEnvMap = map[string]string{
 	"fmtcompat":        "TERRAFMT_FMTCOMPAT",
 	"check":            "TERRAFMT_CHECK",
@@ -44,8 +106,19 @@ var flagEnvMap = map[string]string{
 	"uncoloured":       "TERRAFMT_UNCOLOURED",
 	"pattern":          "TERRAFMT_PATTERN",
 	"fix-finish-lines": "TERRAFMT_FIX_FINISH_LINES",
+	"parallelism":      "TERRAFMT_PARALLELISM",
 	"zero-terminated":  "",
 	"json":             "",
+	"ndjson":           "",
+	"block":            "",
+	"line":             "",
+	"func":             "",
+	"out":              "",
+	"manifest":         "",
+	"format":           "",
+	"patch":            "",
+	"patch-root":       "",
+	"context":          "",
 }
 
 func configureFlags(root *cobra.Command) error {
@@ -84,7 +157,7 @@ func configureFlags(root *cobra.Command) error {
 
 // bindCommandFlags binds the executed command's flags (local + inherited persistent) to
 // viper. This runs from the root PersistentPreRunE rather than configureFlags because
-// fmt and diff each define their own local --pattern flag: a static bind would attach
+// fmt, diff, and blocks each define their own local --pattern flag: a static bind would attach
 // whichever instance was bound last regardless of which command is actually running.
 func bindCommandFlags(cmd *cobra.Command) error {
 	var errs error
@@ -2,12 +2,15 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"testing"
 
 	c "github.com/gookit/color"
+	"github.com/katbyte/terrafmt/lib/blocks"
 	"github.com/katbyte/terrafmt/lib/common"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
 	"github.com/kylelemons/godebug/diff"
 	"github.com/spf13/afero"
 )
@@ -55,11 +58,11 @@ var fmtTestcases = []struct {
 		noDiff:     true,
 		fmtcompat:  false,
 		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: %s:4,3-4:",
-			"block 3 @ %s:30 failed to process with: failed to parse hcl: %s:4,3-4:",
-			"block 4 @ %s:44 failed to process with: failed to parse hcl: %s:3,3-4:",
-			"block 5 @ %s:53 failed to process with: failed to parse hcl: %s:2,26-27:",
-			"block 6 @ %s:67 failed to process with: failed to parse hcl: %s:1,38-39:",
+			"block 1 @ %[1]s:8 failed to process with: failed to parse hcl: %[1]s:12:3:",
+			"block 3 @ %[1]s:30 failed to process with: failed to parse hcl: %[1]s:34:3:",
+			"block 4 @ %[1]s:44 failed to process with: failed to parse hcl: %[1]s:47:3:",
+			"block 5 @ %[1]s:53 failed to process with: failed to parse hcl: %[1]s:55:26:",
+			"block 6 @ %[1]s:67 failed to process with: failed to parse hcl: %[1]s:68:38:",
 		},
 		lineCount:       76,
 		totalBlockCount: 6,
@@ -73,12 +76,21 @@ var fmtTestcases = []struct {
 		updatedBlockCount: 3,
 		totalBlockCount:   6,
 	},
+	{
+		name:              "Go fmt verbs in heredocs, templates, object keys, and dynamic labels --fmtcompat",
+		sourcefile:        "testdata/fmt_contexts.go",
+		resultfile:        "testdata/fmt_contexts_fmtcompat.go",
+		fmtcompat:         true,
+		lineCount:         124,
+		updatedBlockCount: 6,
+		totalBlockCount:   6,
+	},
 	{
 		name:       "Go bad terraform",
 		sourcefile: "testdata/bad_terraform.go",
 		resultfile: "testdata/bad_terraform_fmt.go",
 		errMsg: []string{
-			"block 2 @ %s:16 failed to process with: failed to parse hcl: %s:1,49-50: Unclosed configuration block; There is no closing brace for this block before the end of the file. This may be caused by incorrect brace nesting elsewhere in this file.\\nresource \\",
+			"block 2 @ %[1]s:16 failed to process with: failed to parse hcl: %[1]s:17:49: Unclosed configuration block; There is no closing brace for this block before the end of the file. This may be caused by incorrect brace nesting elsewhere in this file.",
 		},
 		lineCount:         20,
 		updatedBlockCount: 1,
@@ -89,21 +101,35 @@ var fmtTestcases = []struct {
 		sourcefile: "testdata/unsupported_fmt.go",
 		noDiff:     true,
 		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: %s:5,5-6:",
+			"block 1 @ %[1]s:8 failed to process with: failed to parse hcl: %[1]s:13:5:",
 		},
 		lineCount:       21,
 		totalBlockCount: 1,
 	},
 	{
-		name:       "Go unsupported format verbs --fmtcompat",
-		sourcefile: "testdata/unsupported_fmt.go",
-		noDiff:     true,
-		fmtcompat:  true,
-		errMsg: []string{
-			"block 1 @ %s:8 failed to process with: failed to parse hcl: %s:6,17-18:",
-		},
-		lineCount:       21,
-		totalBlockCount: 1,
+		name:              "Go unsupported format verbs --fmtcompat",
+		sourcefile:        "testdata/unsupported_fmt.go",
+		resultfile:        "testdata/unsupported_fmt_fmtcompat.go",
+		fmtcompat:         true,
+		lineCount:         21,
+		updatedBlockCount: 1,
+		totalBlockCount:   1,
+	},
+	{
+		name:              "Go interpreted string literals",
+		sourcefile:        "testdata/has_diffs_quoted.go",
+		resultfile:        "testdata/has_diffs_quoted_fmt.go",
+		lineCount:         21,
+		updatedBlockCount: 2,
+		totalBlockCount:   3,
+	},
+	{
+		name:              "Go concatenated string literals",
+		sourcefile:        "testdata/has_diffs_concat.go",
+		resultfile:        "testdata/has_diffs_concat_fmt.go",
+		lineCount:         36,
+		updatedBlockCount: 4,
+		totalBlockCount:   4,
 	},
 	{
 		name:            "Markdown no change",
@@ -126,9 +152,44 @@ var fmtTestcases = []struct {
 		sourcefile:        "testdata/has_diffs.rst",
 		resultfile:        "testdata/has_diffs_fmt.rst",
 		lineCount:         25,
+		updatedBlockCount: 1,
+		totalBlockCount:   2,
+	},
+	{
+		name:              "Rst directives with options and nesting",
+		skipStdin:         true, // rst is detected by file extension, which stdin does not have
+		sourcefile:        "testdata/has_directives.rst",
+		resultfile:        "testdata/has_directives_fmt.rst",
+		lineCount:         20,
 		updatedBlockCount: 2,
 		totalBlockCount:   2,
 	},
+	{
+		name:              "AsciiDoc formatting",
+		skipStdin:         true, // asciidoc is detected by file extension, which stdin does not have
+		sourcefile:        "testdata/has_diffs.adoc",
+		resultfile:        "testdata/has_diffs_fmt.adoc",
+		lineCount:         26,
+		updatedBlockCount: 1,
+		totalBlockCount:   2,
+	},
+	{
+		name:              "Terraform file formatting",
+		skipStdin:         true, // terraform files are detected by file extension, which stdin does not have
+		sourcefile:        "testdata/has_diffs.tf",
+		resultfile:        "testdata/has_diffs_fmt.tf",
+		lineCount:         16,
+		updatedBlockCount: 1,
+		totalBlockCount:   1,
+	},
+	{
+		name:            "Terraform variables file no change",
+		skipStdin:       true, // terraform files are detected by file extension, which stdin does not have
+		sourcefile:      "testdata/no_diffs.tfvars",
+		noDiff:          true,
+		lineCount:       5,
+		totalBlockCount: 1,
+	},
 	{
 		name:              "Markdown formatting",
 		sourcefile:        "testdata/has_diffs.md",
@@ -146,6 +207,14 @@ var fmtTestcases = []struct {
 		updatedBlockCount: 4,
 		totalBlockCount:   5,
 	},
+	{
+		name:              "Markdown tilde fences and info strings",
+		sourcefile:        "testdata/has_fences.md",
+		resultfile:        "testdata/has_fences_fmt.md",
+		lineCount:         21,
+		updatedBlockCount: 2,
+		totalBlockCount:   2,
+	},
 	{
 		name:              "Markdown indented fences",
 		sourcefile:        "testdata/has_indented.md",
@@ -199,7 +268,7 @@ func TestCmdFmtStdinDefault(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			br, err := formatFile(fs, log, "", testcase.fmtcompat, testcase.fixFinishLines, false, inR, &outB, &errB)
+			res, err := formatFile(context.Background(), fs, "", fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, FixFinishLines: testcase.fixFinishLines, Log: log, Stdin: inR}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -207,8 +276,8 @@ func TestCmdFmtStdinDefault(t *testing.T) {
 				t.Fatalf("Case %q: Got an error when none was expected: %v", testcase.name, err)
 			}
 
-			if len(testcase.errMsg) != br.ErrorBlocks {
-				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), br.ErrorBlocks)
+			if len(testcase.errMsg) != res.ErrorBlocks {
+				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), res.ErrorBlocks)
 			}
 
 			if actualStdOut != expected {
@@ -217,7 +286,7 @@ func TestCmdFmtStdinDefault(t *testing.T) {
 
 			errMsg := []string{}
 			for _, msg := range testcase.errMsg {
-				errMsg = append(errMsg, fmt.Sprintf(msg, "stdin", ""))
+				errMsg = append(errMsg, fmt.Sprintf(msg, "stdin"))
 			}
 			checkExpectedErrors(t, actualStdErr, errMsg)
 		})
@@ -250,7 +319,7 @@ func TestCmdFmtStdinVerbose(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			_, err = formatFile(fs, log, "", testcase.fmtcompat, testcase.fixFinishLines, true, inR, &outB, &errB)
+			_, err = formatFile(context.Background(), fs, "", fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, FixFinishLines: testcase.fixFinishLines, Log: log, Stdin: inR}, Verbose: true}, &outB, &errB)
 			actualStdErr := errB.String()
 
 			if err != nil {
@@ -305,7 +374,7 @@ func TestCmdFmtFileDefault(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			br, err := formatFile(fs, log, testcase.sourcefile, testcase.fmtcompat, testcase.fixFinishLines, false, nil, &outB, &errB)
+			res, err := formatFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, FixFinishLines: testcase.fixFinishLines, Log: log}}, &outB, &errB)
 			actualStdOut := outB.String()
 			actualStdErr := errB.String()
 
@@ -326,13 +395,13 @@ func TestCmdFmtFileDefault(t *testing.T) {
 				t.Errorf("Case %q: File does not match expected: ('-' actual, '+' expected)\n%s", testcase.name, diff.Diff(actualContent, expected))
 			}
 
-			if len(testcase.errMsg) != br.ErrorBlocks {
-				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), br.ErrorBlocks)
+			if len(testcase.errMsg) != res.ErrorBlocks {
+				t.Errorf("Expected %d block errors, got %d", len(testcase.errMsg), res.ErrorBlocks)
 			}
 
 			errMsg := []string{}
 			for _, msg := range testcase.errMsg {
-				errMsg = append(errMsg, fmt.Sprintf(msg, testcase.sourcefile, testcase.sourcefile))
+				errMsg = append(errMsg, fmt.Sprintf(msg, testcase.sourcefile))
 			}
 			checkExpectedErrors(t, actualStdErr, errMsg)
 		})
@@ -354,7 +423,7 @@ func TestCmdFmtFileVerbose(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			_, err := formatFile(fs, log, testcase.sourcefile, testcase.fmtcompat, testcase.fixFinishLines, true, nil, &outB, &errB)
+			_, err := formatFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, FixFinishLines: testcase.fixFinishLines, Log: log}, Verbose: true}, &outB, &errB)
 			actualStdErr := errB.String()
 
 			if err != nil {
@@ -400,8 +469,14 @@ func TestCmdFmtIdempotency(t *testing.T) {
 		{sourcefile: "testdata/has_diffs_fmt_fix_finish.go", fixFinishLines: true},
 		{sourcefile: "testdata/has_diffs_fmt.md"},
 		{sourcefile: "testdata/has_diffs_fmt.rst"},
+		{sourcefile: "testdata/has_diffs_fmt.adoc"},
+		{sourcefile: "testdata/has_directives_fmt.rst"},
+		{sourcefile: "testdata/has_diffs_fmt.tf"},
 		{sourcefile: "testdata/fmt_compat_fmtcompat.go", fmtcompat: true},
+		{sourcefile: "testdata/fmt_contexts_fmtcompat.go", fmtcompat: true},
 		{sourcefile: "testdata/bad_terraform_fmt.go"},
+		{sourcefile: "testdata/has_diffs_quoted_fmt.go"},
+		{sourcefile: "testdata/has_diffs_concat_fmt.go"},
 	}
 
 	for _, testcase := range testcases {
@@ -420,7 +495,7 @@ func TestCmdFmtIdempotency(t *testing.T) {
 			var outB strings.Builder
 			var errB strings.Builder
 			log := common.CreateLogger(&errB)
-			if _, err := formatFile(fs, log, testcase.sourcefile, testcase.fmtcompat, testcase.fixFinishLines, false, nil, &outB, &errB); err != nil {
+			if _, err := formatFile(context.Background(), fs, testcase.sourcefile, fileOptions{Options: terrafmt.Options{FmtCompat: testcase.fmtcompat, FixFinishLines: testcase.fixFinishLines, Log: log}}, &outB, &errB); err != nil {
 				t.Fatalf("Error formatting %q: %s", testcase.sourcefile, err)
 			}
 
@@ -435,3 +510,135 @@ func TestCmdFmtIdempotency(t *testing.T) {
 		})
 	}
 }
+
+// TestCmdFmtGoPreservesSource verifies only the bytes of a reformatted block change: the rest of the
+// file is not gofmt'ed and the comments around the block stay where they are. The source lives here
+// rather than in testdata so `make fmt` does not gofmt it.
+func TestCmdFmtGoPreservesSource(t *testing.T) {
+	t.Parallel()
+
+	source := "package test5\n" +
+		"\n" +
+		"func testNotGofmted(randInt int)   string {\n" +
+		"  x :=   1 // a comment that must stay put\n" +
+		"\t_ = x\n" +
+		"\treturn fmt.Sprintf(/* before */ `\n" +
+		"resource \"azurerm_storage_container\" \"not-gofmted\" {\n" +
+		"  name    = \"tf-test-container-not-gofmted-%d\"\n" +
+		"}\n" +
+		"` /* after */, randInt)\n" +
+		"}\n" +
+		"\n" +
+		"func testAlsoNotGofmted()   string { return `\n" +
+		"resource \"azurerm_storage_container\" \"one-line-func\" {\n" +
+		"  name = \"tf-test-container-one-line-func\"\n" +
+		"}\n" +
+		"` }\n"
+	expected := strings.Replace(source, `name    = "tf-test-container-not-gofmted-%d"`, `name = "tf-test-container-not-gofmted-%d"`, 1)
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "not_gofmted.go", []byte(source), 0o644); err != nil {
+		t.Fatalf("Error writing test input file: %s", err)
+	}
+
+	var outB strings.Builder
+	var errB strings.Builder
+	log := common.CreateLogger(&errB)
+	if _, err := formatFile(context.Background(), fs, "not_gofmted.go", fileOptions{Options: terrafmt.Options{Log: log}}, &outB, &errB); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	data, err := afero.ReadFile(fs, "not_gofmted.go")
+	if err != nil {
+		t.Fatalf("Error reading results: %s", err)
+	}
+	if string(data) != expected {
+		t.Errorf("File does not match expected: ('-' actual, '+' expected)\n%s", diff.Diff(string(data), expected))
+	}
+}
+
+// TestCmdFmtUnchangedFileNotWritten verifies files that need no formatting are not written to at all,
+// so their modification time is kept and file watchers are not triggered.
+func TestCmdFmtUnchangedFileNotWritten(t *testing.T) {
+	t.Parallel()
+
+	for _, sourcefile := range []string{
+		"testdata/no_diffs.go",
+		"testdata/no_diffs.md",
+		"testdata/has_diffs_fmt.rst",
+		"testdata/no_diffs.tfvars",
+	} {
+		t.Run(sourcefile, func(t *testing.T) {
+			t.Parallel()
+
+			// anything written ends up in the in-memory layer
+			layer := afero.NewMemMapFs()
+			fs := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(afero.NewOsFs()), layer)
+
+			var outB strings.Builder
+			var errB strings.Builder
+			log := common.CreateLogger(&errB)
+			if _, err := formatFile(context.Background(), fs, sourcefile, fileOptions{Options: terrafmt.Options{Log: log}}, &outB, &errB); err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			if exists, err := afero.Exists(layer, sourcefile); err != nil || exists {
+				t.Errorf("Expected %q not to be written (exists: %t, err: %v)", sourcefile, exists, err)
+			}
+		})
+	}
+}
+
+func TestCmdFmtSelect(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		selection blocks.Selection
+	}{
+		{
+			name:      "func",
+			selection: blocks.Selection{Func: "testExtraSpace"},
+		},
+		{
+			name:      "block",
+			selection: blocks.Selection{Number: 3},
+		},
+		{
+			name:      "line",
+			selection: blocks.Selection{Line: 28},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(afero.NewOsFs()), afero.NewMemMapFs())
+			original, err := afero.ReadFile(fs, "testdata/has_diffs.go")
+			if err != nil {
+				t.Fatalf("Error reading test input file: %s", err)
+			}
+			expected := strings.Replace(string(original), `name    = "tf-test-container-extra-space-%d"`, `name = "tf-test-container-extra-space-%d"`, 1)
+
+			var outB strings.Builder
+			var errB strings.Builder
+			log := common.CreateLogger(&errB)
+			res, err := formatFile(context.Background(), fs, "testdata/has_diffs.go", fileOptions{Options: terrafmt.Options{Select: testcase.selection, Log: log}}, &outB, &errB)
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if res.ChangedBlocks != 1 {
+				t.Errorf("Expected 1 changed block, got %d", res.ChangedBlocks)
+			}
+
+			data, err := afero.ReadFile(fs, "testdata/has_diffs.go")
+			if err != nil {
+				t.Fatalf("Error reading test result file: %s", err)
+			}
+			if string(data) != expected {
+				t.Errorf("Only the selected block should be formatted: ('-' actual, '+' expected)\n%s", diff.Diff(string(data), expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/katbyte/terrafmt/lib/terrafmt"
+)
+
+// fileProcessor processes a single file, writing anything for the user to stdout and stderr, and
+// returns the exit code bits for the file along with any error.
+type fileProcessor func(filename string, stdout, stderr io.Writer) (int, error)
+
+type fileResult struct {
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	exitCode int
+	err      error
+}
+
+// processFiles runs process for every file in filenames with terrafmt.ProcessFiles. Each file gets its
+// own output buffers which are copied to stdout and stderr in sorted file order, so the output is
+// the same no matter how the files were scheduled. The errors of all files are merged, and their exit
+// codes combined.
+func processFiles(ctx context.Context, filenames []string, parallelism int, stdout, stderr io.Writer, process fileProcessor) (int, error) {
+	var errs *multierror.Error
+	exitCode := ExitCodeNoError
+
+	err := terrafmt.ProcessFiles(ctx, filenames, parallelism,
+		func(filename string) *fileResult {
+			r := &fileResult{}
+			r.exitCode, r.err = process(filename, &r.stdout, &r.stderr)
+
+			return r
+		},
+		func(_ string, r *fileResult) {
+			// best-effort console output, like the rest of the command output
+			_, _ = io.Copy(stdout, &r.stdout)
+			_, _ = io.Copy(stderr, &r.stderr)
+
+			if r.err != nil {
+				errs = multierror.Append(errs, r.err)
+			}
+			exitCode |= r.exitCode
+		},
+	)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	return exitCode, errs.ErrorOrNil()
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProcessFilesDeterministicOutput(t *testing.T) {
+	t.Parallel()
+
+	filenames := []string{"d.md", "b.md", "e.md", "a.md", "c.md"}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := processFiles(context.Background(), filenames, 3, &stdout, &stderr, func(filename string, stdout, stderr io.Writer) (int, error) {
+		// finish the files in the reverse of the order their output is expected in
+		time.Sleep(time.Duration('f'-filename[0]) * 5 * time.Millisecond)
+		fmt.Fprintf(stdout, "%s\n", filename)
+		fmt.Fprintf(stderr, "%s done\n", filename)
+
+		switch filename {
+		case "b.md":
+			return ExitCodeBlockParsingError, nil
+		case "d.md":
+			return ExitCodeFormattingDiffError, errors.New("d failed")
+		}
+
+		return ExitCodeNoError, nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "d failed") {
+		t.Errorf("Expected the error of d.md, got %v", err)
+	}
+	if expected := ExitCodeBlockParsingError | ExitCodeFormattingDiffError; exitCode != expected {
+		t.Errorf("Expected exit code %d, got %d", expected, exitCode)
+	}
+	if expected := "a.md\nb.md\nc.md\nd.md\ne.md\n"; stdout.String() != expected {
+		t.Errorf("Expected stdout %q, got %q", expected, stdout.String())
+	}
+	if expected := "a.md done\nb.md done\nc.md done\nd.md done\ne.md done\n"; stderr.String() != expected {
+		t.Errorf("Expected stderr %q, got %q", expected, stderr.String())
+	}
+}
+
+func TestProcessFilesBoundedParallelism(t *testing.T) {
+	t.Parallel()
+
+	filenames := make([]string, 20)
+	for i := range filenames {
+		filenames[i] = fmt.Sprintf("%02d.md", i)
+	}
+
+	var running, maxRunning atomic.Int32
+	_, err := processFiles(context.Background(), filenames, 4, io.Discard, io.Discard, func(_ string, _, _ io.Writer) (int, error) {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+
+		return ExitCodeNoError, nil
+	})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if m := maxRunning.Load(); m > 4 {
+		t.Errorf("Expected at most 4 files to be processed at the same time, got %d", m)
+	}
+}
+
+func TestProcessFilesCancelled(t *testing.T) {
+	t.Parallel()
+
+	filenames := []string{"a.md", "b.md", "c.md", "d.md"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout bytes.Buffer
+	_, err := processFiles(ctx, filenames, 1, &stdout, io.Discard, func(filename string, stdout, _ io.Writer) (int, error) {
+		if filename == "b.md" {
+			cancel()
+		}
+		fmt.Fprintf(stdout, "%s\n", filename)
+
+		return ExitCodeNoError, nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+	// the file being processed when cancelled is finished, the rest are never started
+	if expected := "a.md\nb.md\n"; stdout.String() != expected {
+		t.Errorf("Expected stdout %q, got %q", expected, stdout.String())
+	}
+}
@@ -0,0 +1,450 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/katbyte/terrafmt/lib/terrafmt"
+	"github.com/katbyte/terrafmt/lib/version"
+)
+
+// the report formats of the diff command, text is the coloured diff
+const (
+	ReportFormatText       = "text"
+	ReportFormatSARIF      = "sarif"
+	ReportFormatCheckstyle = "checkstyle"
+	ReportFormatJUnit      = "junit"
+	ReportFormatGitHub     = "github"
+)
+
+// reportFile is a file of a report: the blocks that were read from it, and the error that stopped it
+// being read (e.g. a block that is never closed), if any
+type reportFile struct {
+	*terrafmt.FileResult
+	Err error
+}
+
+var reportWriters = map[string]func(io.Writer, []reportFile) error{
+	ReportFormatSARIF:      writeSARIFReport,
+	ReportFormatCheckstyle: writeCheckstyleReport,
+	ReportFormatJUnit:      writeJUnitReport,
+	ReportFormatGitHub:     writeGitHubReport,
+}
+
+// reportFormats returns the names of the report formats, for help and errors
+func reportFormats() []string {
+	formats := []string{ReportFormatText}
+	for name := range reportWriters {
+		formats = append(formats, name)
+	}
+	slices.Sort(formats[1:])
+
+	return formats
+}
+
+const (
+	ruleUnformatted = "unformatted"
+	ruleParseError  = "parse-error"
+	ruleFileError   = "file-error"
+)
+
+// reportEntry is a problem with a block: an unformatted block covers the lines of its content, while
+// a parse error points at the position of its diagnostic
+type reportEntry struct {
+	File      string
+	Rule      string
+	Message   string
+	StartLine int
+	EndLine   int
+	Column    int // 0 for a whole block
+}
+
+// blockEntries returns the problems with a block, none for a formatted block
+func blockEntries(file *terrafmt.FileResult, b terrafmt.BlockResult) []reportEntry {
+	switch b.Status {
+	case terrafmt.BlockNeedsFormatting:
+		return []reportEntry{{
+			File:      file.Filename,
+			Rule:      ruleUnformatted,
+			Message:   fmt.Sprintf("block %d is not formatted, run terrafmt fmt", b.Number),
+			StartLine: b.ContentStart.Line,
+			EndLine:   b.ContentEnd.Line,
+		}}
+
+	case terrafmt.BlockError:
+		if len(b.Diagnostics) == 0 {
+			return []reportEntry{{
+				File:      file.Filename,
+				Rule:      ruleParseError,
+				Message:   fmt.Sprintf("block %d could not be parsed: %v", b.Number, b.Err),
+				StartLine: b.ContentStart.Line,
+				EndLine:   b.ContentEnd.Line,
+			}}
+		}
+
+		entries := make([]reportEntry, 0, len(b.Diagnostics))
+		for _, d := range b.Diagnostics {
+			msg := d.Summary
+			if d.Detail != "" {
+				msg += "; " + d.Detail
+			}
+			entries = append(entries, reportEntry{
+				File:      file.Filename,
+				Rule:      ruleParseError,
+				Message:   msg,
+				StartLine: d.Pos.Line,
+				EndLine:   d.Pos.Line,
+				Column:    d.Pos.Column,
+			})
+		}
+
+		return entries
+	}
+
+	return nil
+}
+
+// fileEntry returns the problem with a file that could not be read, it is reported at its first line
+func fileEntry(f reportFile) reportEntry {
+	return reportEntry{
+		File:      f.Filename,
+		Rule:      ruleFileError,
+		Message:   fmt.Sprintf("%s could not be read: %v", f.Filename, f.Err),
+		StartLine: 1,
+		EndLine:   1,
+	}
+}
+
+func reportEntries(files []reportFile) []reportEntry {
+	var entries []reportEntry
+	for _, f := range files {
+		for _, b := range f.Blocks {
+			entries = append(entries, blockEntries(f.FileResult, b)...)
+		}
+		if f.Err != nil {
+			entries = append(entries, fileEntry(f))
+		}
+	}
+
+	return entries
+}
+
+// SARIF 2.1.0, only the parts of the schema that are used
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifSourceRoot is the base of the uris of the files below the working directory
+const sarifSourceRoot = "%SRCROOT%"
+
+// sarifArtifact returns the location of filename for a report made in dir: a percent-encoded uri
+// relative to sarifSourceRoot for files below dir, and an absolute file uri for any others
+func sarifArtifact(filename, dir string) sarifArtifactLocation {
+	abs := filename
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(dir, filename)
+	}
+
+	if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return sarifArtifactLocation{
+			URI:       (&url.URL{Path: filepath.ToSlash(rel)}).String(),
+			URIBaseID: sarifSourceRoot,
+		}
+	}
+
+	return sarifArtifactLocation{URI: fileURI(abs)}
+}
+
+// fileURI returns the file uri of the absolute path
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // a windows drive, C:/...
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	EndLine     int `json:"endLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func writeSARIFReport(w io.Writer, files []reportFile) error {
+	rules := []sarifRule{
+		{ID: ruleUnformatted, ShortDescription: sarifMessage{Text: "Terraform block is not formatted"}},
+		{ID: ruleParseError, ShortDescription: sarifMessage{Text: "Terraform block could not be parsed"}},
+		{ID: ruleFileError, ShortDescription: sarifMessage{Text: "File could not be read"}},
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	results := []sarifResult{}
+	for _, e := range reportEntries(files) {
+		results = append(results, sarifResult{
+			RuleID:    e.Rule,
+			RuleIndex: slices.IndexFunc(rules, func(r sarifRule) bool { return r.ID == e.Rule }),
+			Level:     "error",
+			Message:   sarifMessage{Text: e.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifact(e.File, dir),
+					Region:           sarifRegion{StartLine: e.StartLine, EndLine: e.EndLine, StartColumn: e.Column},
+				},
+			}},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "terrafmt",
+				Version:        version.Version,
+				InformationURI: "https://github.com/katbyte/terrafmt",
+				Rules:          rules,
+			}},
+			OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+				sarifSourceRoot: {URI: strings.TrimSuffix(fileURI(dir), "/") + "/"},
+			},
+			Results: results,
+		}},
+	})
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func writeCheckstyleReport(w io.Writer, files []reportFile) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, f := range files {
+		file := checkstyleFile{Name: f.Filename}
+		for _, b := range f.Blocks {
+			for _, e := range blockEntries(f.FileResult, b) {
+				msg := e.Message
+				if e.Column == 0 {
+					// checkstyle only has a line, so give the rest of the range in the message
+					msg = fmt.Sprintf("%s (lines %d-%d)", msg, e.StartLine, e.EndLine)
+				}
+				file.Errors = append(file.Errors, checkstyleError{
+					Line:     e.StartLine,
+					Column:   e.Column,
+					Severity: "error",
+					Message:  msg,
+					Source:   "terrafmt." + e.Rule,
+				})
+			}
+		}
+		if f.Err != nil {
+			e := fileEntry(f)
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     e.StartLine,
+				Severity: "error",
+				Message:  e.Message,
+				Source:   "terrafmt." + e.Rule,
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	return writeXML(w, report)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes a test suite for each file with a test case for each of its blocks, blocks
+// that need formatting fail and blocks that could not be parsed are errors. A file that could not be
+// read has an extra test case for the whole file, which is an error.
+func writeJUnitReport(w io.Writer, files []reportFile) error {
+	report := junitTestSuites{Name: "terrafmt"}
+	for _, f := range files {
+		suite := junitTestSuite{Name: f.Filename, Tests: len(f.Blocks)}
+		for _, b := range f.Blocks {
+			tc := junitTestCase{
+				Name:      fmt.Sprintf("%s:%d-%d", f.Filename, b.ContentStart.Line, b.ContentEnd.Line),
+				ClassName: f.Filename,
+			}
+
+			var lines []string
+			entries := blockEntries(f.FileResult, b)
+			for _, e := range entries {
+				lines = append(lines, fmt.Sprintf("%s:%d: %s", e.File, e.StartLine, e.Message))
+			}
+
+			switch b.Status {
+			case terrafmt.BlockNeedsFormatting:
+				tc.Failure = &junitProblem{Message: entries[0].Message, Type: ruleUnformatted, Text: strings.Join(lines, "\n")}
+				suite.Failures++
+			case terrafmt.BlockError:
+				tc.Error = &junitProblem{Message: entries[0].Message, Type: ruleParseError, Text: strings.Join(lines, "\n")}
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		if f.Err != nil {
+			e := fileEntry(f)
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      f.Filename,
+				ClassName: f.Filename,
+				Error:     &junitProblem{Message: e.Message, Type: ruleFileError, Text: fmt.Sprintf("%s:%d: %s", e.File, e.StartLine, e.Message)},
+			})
+			suite.Tests++
+			suite.Errors++
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+
+	return writeXML(w, report)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+var (
+	gitHubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	gitHubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// writeGitHubReport writes a GitHub Actions ::error workflow command for each problem, which are
+// shown as annotations on the lines of the pull request
+func writeGitHubReport(w io.Writer, files []reportFile) error {
+	for _, e := range reportEntries(files) {
+		props := fmt.Sprintf("file=%s,line=%d,endLine=%d", gitHubPropertyEscaper.Replace(e.File), e.StartLine, e.EndLine)
+		if e.Column > 0 {
+			props += fmt.Sprintf(",col=%d", e.Column)
+		}
+		props += ",title=" + gitHubPropertyEscaper.Replace("terrafmt "+e.Rule)
+
+		if _, err := fmt.Fprintf(w, "::error %s::%s\n", props, gitHubDataEscaper.Replace(e.Message)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katbyte/terrafmt/lib/terrafmt"
+	"github.com/spf13/afero"
+)
+
+const reportTestFile = "docs/example.md"
+
+const reportTestSource = "# Example\n" +
+	"\n" +
+	"```hcl\n" +
+	"resource \"aws_s3_bucket\" \"test\" {\n" +
+	"bucket=\"test\"\n" +
+	"}\n" +
+	"```\n" +
+	"\n" +
+	"```hcl\n" +
+	"resource \"aws_s3_bucket\" \"formatted\" {\n" +
+	"  bucket = \"formatted\"\n" +
+	"}\n" +
+	"```\n" +
+	"\n" +
+	"```hcl\n" +
+	"resource \"aws_s3_bucket\" \"broken\" {\n" +
+	"  bucket = \"broken\"\n" +
+	"```\n"
+
+func reportTestFiles(t *testing.T) []reportFile {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, reportTestFile, []byte(reportTestSource), 0o644); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	res, err := terrafmt.DiffFile(context.Background(), fs, reportTestFile, terrafmt.Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	return []reportFile{{FileResult: res}}
+}
+
+func TestReportEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := reportEntries(reportTestFiles(t))
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d: %+v", len(entries), entries)
+	}
+
+	unformatted := entries[0]
+	if unformatted.Rule != ruleUnformatted || unformatted.StartLine != 4 || unformatted.EndLine != 6 || unformatted.Column != 0 {
+		t.Errorf("Expected an unformatted entry for lines 4-6, got %+v", unformatted)
+	}
+
+	parseErr := entries[1]
+	if parseErr.Rule != ruleParseError || parseErr.StartLine != 16 || parseErr.Column != 35 {
+		t.Errorf("Expected a parse error entry at 16:35, got %+v", parseErr)
+	}
+	if !strings.HasPrefix(parseErr.Message, "Unclosed configuration block") {
+		t.Errorf("Expected the parse error's summary in its message, got %q", parseErr.Message)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		format   string
+		validate func(t *testing.T, output string)
+	}{
+		{
+			name:   "sarif",
+			format: ReportFormatSARIF,
+			validate: func(t *testing.T, output string) {
+				var log sarifLog
+				if err := json.Unmarshal([]byte(output), &log); err != nil {
+					t.Fatalf("Expected valid JSON, got %v", err)
+				}
+				if log.Version != "2.1.0" || len(log.Runs) != 1 {
+					t.Fatalf("Expected a single SARIF 2.1.0 run, got %+v", log)
+				}
+
+				results := log.Runs[0].Results
+				if len(results) != 2 {
+					t.Fatalf("Expected 2 results, got %d", len(results))
+				}
+				region := results[0].Locations[0].PhysicalLocation.Region
+				if results[0].RuleID != ruleUnformatted || region.StartLine != 4 || region.EndLine != 6 {
+					t.Errorf("Expected the unformatted block at lines 4-6, got %+v", results[0])
+				}
+				if loc := results[0].Locations[0].PhysicalLocation.ArtifactLocation; loc.URI != reportTestFile || loc.URIBaseID != sarifSourceRoot {
+					t.Errorf("Expected the uri %q relative to %s, got %+v", reportTestFile, sarifSourceRoot, loc)
+				}
+				if results[1].RuleID != ruleParseError || results[1].RuleIndex != 1 {
+					t.Errorf("Expected the parse error to reference its rule, got %+v", results[1])
+				}
+			},
+		},
+		{
+			name:   "checkstyle",
+			format: ReportFormatCheckstyle,
+			validate: func(t *testing.T, output string) {
+				var report checkstyleReport
+				if err := xml.Unmarshal([]byte(output), &report); err != nil {
+					t.Fatalf("Expected valid XML, got %v", err)
+				}
+				if len(report.Files) != 1 || len(report.Files[0].Errors) != 2 {
+					t.Fatalf("Expected a file with 2 errors, got %+v", report)
+				}
+				if e := report.Files[0].Errors[0]; e.Line != 4 || e.Source != "terrafmt.unformatted" || !strings.HasSuffix(e.Message, "(lines 4-6)") {
+					t.Errorf("Expected the unformatted block at lines 4-6, got %+v", e)
+				}
+				if e := report.Files[0].Errors[1]; e.Line != 16 || e.Column != 35 || e.Source != "terrafmt.parse-error" {
+					t.Errorf("Expected the parse error at 16:35, got %+v", e)
+				}
+			},
+		},
+		{
+			name:   "junit",
+			format: ReportFormatJUnit,
+			validate: func(t *testing.T, output string) {
+				var report junitTestSuites
+				if err := xml.Unmarshal([]byte(output), &report); err != nil {
+					t.Fatalf("Expected valid XML, got %v", err)
+				}
+				if report.Tests != 3 || report.Failures != 1 || report.Errors != 1 {
+					t.Errorf("Expected 3 tests with 1 failure and 1 error, got %d/%d/%d", report.Tests, report.Failures, report.Errors)
+				}
+
+				cases := report.Suites[0].Cases
+				if len(cases) != 3 {
+					t.Fatalf("Expected 3 test cases, got %d", len(cases))
+				}
+				if cases[0].Name != reportTestFile+":4-6" || cases[0].Failure == nil {
+					t.Errorf("Expected the first block to fail, got %+v", cases[0])
+				}
+				if cases[1].Failure != nil || cases[1].Error != nil {
+					t.Errorf("Expected the second block to pass, got %+v", cases[1])
+				}
+				if cases[2].Error == nil {
+					t.Errorf("Expected the third block to be an error, got %+v", cases[2])
+				}
+			},
+		},
+		{
+			name:   "github",
+			format: ReportFormatGitHub,
+			validate: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+				if len(lines) != 2 {
+					t.Fatalf("Expected 2 annotations, got %q", output)
+				}
+				if expected := "::error file=docs/example.md,line=4,endLine=6,title=terrafmt unformatted::block 1 is not formatted, run terrafmt fmt"; lines[0] != expected {
+					t.Errorf("Expected %q, got %q", expected, lines[0])
+				}
+				if expected := "::error file=docs/example.md,line=16,endLine=16,col=35,title=terrafmt parse-error::Unclosed configuration block"; !strings.HasPrefix(lines[1], expected) {
+					t.Errorf("Expected %q to start with %q", lines[1], expected)
+				}
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+			if err := reportWriters[testcase.format](&output, reportTestFiles(t)); err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			testcase.validate(t, output.String())
+		})
+	}
+}
+
+func TestGitHubReportEscaping(t *testing.T) {
+	t.Parallel()
+
+	files := []reportFile{{FileResult: &terrafmt.FileResult{
+		Filename: "a,b:c.md",
+		Blocks: []terrafmt.BlockResult{{
+			Number: 1,
+			Status: terrafmt.BlockError,
+			Diagnostics: []terrafmt.Diagnostic{{
+				Summary: "100% broken",
+				Detail:  "line one\nline two",
+			}},
+		}},
+	}}}
+
+	var output bytes.Buffer
+	if err := writeGitHubReport(&output, files); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if expected := "::error file=a%2Cb%3Ac.md,line=0,endLine=0,title=terrafmt parse-error::100%25 broken; line one%0Aline two\n"; output.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestWriteReportFileError(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "docs/unclosed.md", []byte("# Example\n\n```hcl\nresource \"a\" \"b\" {}\n"), 0o644); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	_, err := terrafmt.DiffFile(context.Background(), fs, "docs/unclosed.md", terrafmt.Options{})
+	if err == nil {
+		t.Fatal("Expected an error reading a block that is never closed")
+	}
+	files := []reportFile{{FileResult: &terrafmt.FileResult{Filename: "docs/unclosed.md"}, Err: err}}
+
+	for _, format := range reportFormats()[1:] {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+			if err := reportWriters[format](&output, files); err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			if !strings.Contains(output.String(), "docs/unclosed.md could not be read") || !strings.Contains(output.String(), ruleFileError) {
+				t.Errorf("Expected the file error in the report, got:\n%s", output.String())
+			}
+		})
+	}
+}
+
+func TestSARIFArtifact(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		filename string
+		expected sarifArtifactLocation
+	}{
+		{
+			filename: "docs/my example#1.md",
+			expected: sarifArtifactLocation{URI: "docs/my%20example%231.md", URIBaseID: sarifSourceRoot},
+		},
+		{
+			filename: "/src/project/docs/a.md",
+			expected: sarifArtifactLocation{URI: "docs/a.md", URIBaseID: sarifSourceRoot},
+		},
+		{
+			filename: "c:d.md",
+			expected: sarifArtifactLocation{URI: "./c:d.md", URIBaseID: sarifSourceRoot},
+		},
+		{
+			filename: "../other/a b.md",
+			expected: sarifArtifactLocation{URI: "file:///src/other/a%20b.md"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		if actual := sarifArtifact(filepath.FromSlash(testcase.filename), filepath.FromSlash("/src/project")); actual != testcase.expected {
+			t.Errorf("Expected %+v for %q, got %+v", testcase.expected, testcase.filename, actual)
+		}
+	}
+}
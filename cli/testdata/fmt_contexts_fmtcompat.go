@@ -0,0 +1,124 @@
+package test4
+
+import (
+	"fmt"
+)
+
+func testAccRoleDefinition_heredoc(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+resource "azurerm_policy_definition" "test" {
+  name        = "acctestpol-%[1]d"
+  policy_rule = <<POLICY
+{
+  "if": {
+    "not": {
+      "field": "location",
+      "in": "%[2]s"
+    }
+  },
+  "then": {
+    "effect": "%s"
+  }
+}
+POLICY
+
+  metadata = <<-METADATA
+    {
+      "category": "${%[3]q}",
+      "version": %d
+    }
+  METADATA
+}
+`, data.RandomInteger, data.Locations.Primary, "audit", "General", 1)
+}
+
+func testAccStorageAccount_templates(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+resource "azurerm_storage_account" "test" {
+  name                = "unlikely23exst2acct%s"
+  resource_group_name = "${azurerm_resource_group.%s.name}"
+  location            = "${upper(%q)}-${var.suffixes[%d]}"
+  account_tier        = "Standard"
+}
+`, data.RandomString, "test", data.Locations.Primary, 0)
+}
+
+func testAccVirtualNetwork_tags(data acceptance.TestData, key, value string) string {
+	return fmt.Sprintf(`
+resource "azurerm_virtual_network" "test" {
+  name          = "acctestvirtnet%[1]d"
+  address_space = ["10.0.0.0/16"]
+
+  tags = { %[2]s = "first" }
+
+  labels = {
+    %[2]q         = %[3]q
+    %[3]s         = "second"
+    "environment" = %[2]q
+  }
+}
+`, data.RandomInteger, key, value)
+}
+
+func testAccSubnet_forEach(data acceptance.TestData, subnets string) string {
+	return fmt.Sprintf(`
+resource "azurerm_subnet" "test" {
+  for_each = %s
+
+  name                = each.key
+  resource_group_name = azurerm_resource_group.test.name
+  address_prefixes    = [each.value]
+}
+
+resource "azurerm_network_security_group" "test" {
+  for_each = { for k, v in %s : k => v if v != %q }
+  name     = "acctestnsg-${each.key}"
+}
+`, subnets, subnets, "")
+}
+
+func testAccLinuxVirtualMachine_dependsOn(data acceptance.TestData, extra string) string {
+	return fmt.Sprintf(`
+resource "azurerm_linux_virtual_machine" "test" {
+  name = "acctestVM-%d"
+
+  depends_on = [%s]
+}
+
+resource "azurerm_virtual_machine_extension" "test" {
+  name = "acctestExt-%[1]d"
+
+  depends_on = [azurerm_linux_virtual_machine.test, %[2]s, azurerm_subnet.test]
+}
+
+resource "azurerm_virtual_machine_extension" "other" {
+  name = "acctestExt2-%[1]d"
+
+  depends_on = [
+    azurerm_linux_virtual_machine.test,
+    %[2]s,
+  ]
+}
+`, data.RandomInteger, extra)
+}
+
+func testAccKubernetesCluster_dynamic(data acceptance.TestData, block, attribute string) string {
+	return fmt.Sprintf(`
+resource "azurerm_kubernetes_cluster" "test" {
+  name = "acctestaks%d"
+
+  dynamic "%s" {
+    for_each = var.pools
+    content {
+      name  = %[2]s.value.name
+      %[3]s = %[2]s.key
+    }
+  }
+
+  dynamic %q {
+    for_each = []
+    content {}
+  }
+}
+`, data.RandomInteger, block, attribute, block)
+}
@@ -0,0 +1,16 @@
+terraform {
+  required_providers {
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+  }
+}
+
+resource "azurerm_resource_group" "example" {
+  name = "example"
+    location = "West Europe"
+}
+
+variable "prefix" {
+  type    = string
+}
@@ -0,0 +1,36 @@
+package test4
+
+import (
+	"fmt"
+)
+
+func testConcatenatedInterpreted(randInt int) string {
+	return fmt.Sprintf("\n"+
+		"resource \"azurerm_storage_container\" \"concat-interpreted\" {\n"+
+		"  name    = \"tf-test-container-concat-interpreted-%d\"\n"+
+		"}\n", randInt)
+}
+
+func testConcatenatedRaw() string {
+	return `
+resource "azurerm_storage_container" "concat-raw" {
+` + `  name    = "tf-test-container-concat-raw"
+}
+`
+}
+
+func testConcatenatedMixed(randInt int) string {
+	return testConcatenatedRaw() + fmt.Sprintf(`
+resource "azurerm_storage_container" "concat-mixed" {
+  name    = "tf-test-container-concat-mixed-%d"
+}
+`, randInt)
+}
+
+func testConcatenatedMidLine() string {
+	return `
+resource "azurerm_storage_container" "concat-mid-line" {
+  name    = ` + `"tf-test-container-concat-mid-line"
+}
+`
+}
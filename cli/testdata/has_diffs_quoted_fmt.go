@@ -0,0 +1,21 @@
+package test3
+
+import (
+	"fmt"
+)
+
+func testInterpretedString(randInt int) string {
+	return fmt.Sprintf("\nresource \"azurerm_storage_container\" \"interpreted\" {\n  name = \"tf-test-container-interpreted-%d\"\n}\n", randInt)
+}
+
+func testInterpretedStringNoChange() string {
+	return "\nresource \"azurerm_storage_container\" \"interpreted-no-change\" {\n  name = \"tf-test-container-interpreted-no-change\"\n}\n"
+}
+
+func testRawString() string {
+	return `
+resource "azurerm_storage_container" "raw" {
+  name = "tf-test-container-raw"
+}
+`
+}
@@ -1,4 +1,6 @@
-// Package blocks finds and extracts terraform blocks embedded in go, markdown, and rst files.
+// Package blocks finds and extracts terraform blocks embedded in go files and text documents
+// (markdown, rst, asciidoc, ...). Scan and Rewrite work on source in memory, while Reader processes
+// files in place.
 package blocks
 
 import (
@@ -6,14 +8,14 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/parser"
 	"go/token"
 	"io"
-	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -24,8 +26,11 @@ var lineWithLeadingSpacesMatcher = regexp.MustCompile("^[\\s\\v]*(.*\n)$") // [\
 
 type blockReadFunc func(*Reader, int, string, bool) error
 
+// BlockWriter receives the blocks extracted by the blocks command. startLine and endLine are the
+// lines the block starts and ends on (for text documents the lines opening and closing it), and text
+// is what to write for it, which can differ from b.Text.
 type BlockWriter interface {
-	Write(index, startLine, endLine int, text string)
+	Write(b Block, startLine, endLine int, text string)
 	Close() error
 }
 
@@ -49,11 +54,37 @@ type Reader struct {
 	CurrentNodeLeadingPadding  string
 	CurrentNodeTrailingPadding string
 
+	// the replacements made by ReplaceCurrentNode in the go source
+	goEdits []sourceEdit
+
+	// where the current block is in the source, how it is embedded there and its language, see Block
+	currentStart    token.Position
+	currentEnd      token.Position
+	currentHost     HostContext
+	currentLanguage string
+	currentText     string
+	currentFunc     string // go only, the function the current block is in
+
+	// for go, the source, its file, the source offset of each byte of the current block's text, and
+	// the string literals it is read from
+	goSrc           []byte
+	goFile          *token.File
+	currentOffsets  []int
+	currentLiterals []goLiteral
+
+	// for go, the fmt calls whose format is each string expression, see CurrentFormatCalls
+	goFormatCalls map[ast.Expr][]FormatCall
+
 	ErrorBlocks int
 
+	// the document DoTheThing read and its kind, e.g. to compare the blocks against
+	Source []byte
+	Kind   Kind
+
 	// options
-	ReadOnly       bool
+	ReadOnly       bool // files are left as they are, BlockRead can set it to stop a file being written
 	FixFinishLines bool
+	Select         Selection // blocks that are not selected are left as they are without calling BlockRead
 
 	// callbacks
 	LineRead  func(*Reader, int, string) error
@@ -84,65 +115,285 @@ var (
 )
 
 func (bv blockVisitor) Visit(cursor *astutil.Cursor) bool {
-	if node, ok := cursor.Node().(*ast.BasicLit); ok && node.Kind == token.STRING {
-		if unquoted, err := strconv.Unquote(node.Value); err == nil && looksLikeTerraform(unquoted) {
-			value := strings.Trim(unquoted, " \t")
-			value = strings.TrimPrefix(value, "\n")
-
-			if strings.Contains(value, "\n") {
-				bv.br.CurrentNodeCursor = cursor
-				bv.br.CurrentNodeQuoteChar = node.Value[0:1]
-				bv.br.CurrentNodeLeadingPadding = leadingPaddingMatcher.FindString(unquoted)
-				bv.br.CurrentNodeTrailingPadding = trailingPaddingMatcher.FindString(unquoted)
-				bv.br.BlockCount++
-				bv.br.LineCount = bv.fset.Position(node.End()).Line
-
-				// This is to deal with some outputs using just LineCount and some using LineCount-BlockCurrentLine
-				bv.br.BlockCurrentLine = bv.fset.Position(node.End()).Line - bv.fset.Position(node.Pos()).Line
-
-				err := bv.f(bv.br, 0, value, false)
-				if err != nil {
-					bv.br.ErrorBlocks++
-					bv.br.Log.Errorf("block %d @ %s:%d failed to process with: %v", bv.br.BlockCount, bv.br.FileName, bv.fset.Position(node.Pos()).Line, err)
+	if fd, ok := cursor.Node().(*ast.FuncDecl); ok {
+		bv.br.currentFunc = funcDeclName(fd)
+		return true
+	}
+
+	node, ok := cursor.Node().(ast.Expr)
+	if !ok {
+		return true
+	}
+
+	unquoted, quoteChar, ok := stringExprValue(node)
+	if !ok || !looksLikeTerraform(unquoted) {
+		return true
+	}
+
+	value := strings.Trim(unquoted, " \t")
+	value = strings.TrimPrefix(value, "\n")
+
+	if !strings.Contains(value, "\n") {
+		return true
+	}
+
+	bv.br.CurrentNodeCursor = cursor
+	bv.br.CurrentNodeQuoteChar = quoteChar
+	bv.br.CurrentNodeLeadingPadding = leadingPaddingMatcher.FindString(unquoted)
+	bv.br.CurrentNodeTrailingPadding = trailingPaddingMatcher.FindString(unquoted)
+	bv.br.currentStart = bv.fset.Position(node.Pos())
+	bv.br.currentEnd = bv.fset.Position(node.End())
+	bv.br.currentHost = HostContext{
+		Quote:           quoteChar,
+		LeadingPadding:  bv.br.CurrentNodeLeadingPadding,
+		TrailingPadding: bv.br.CurrentNodeTrailingPadding,
+	}
+	bv.br.currentLanguage = ""
+	bv.br.currentText = value
+	bv.br.currentOffsets = stringExprOffsets(node, bv.br.goFile)[len(unquoted)-len(strings.TrimPrefix(strings.TrimLeft(unquoted, " \t"), "\n")):]
+	bv.br.currentLiterals = stringExprLiterals(node, bv.br.goFile)
+	bv.br.BlockCount++
+	bv.br.LineCount = bv.fset.Position(node.End()).Line
+
+	// This is to deal with some outputs using just LineCount and some using LineCount-BlockCurrentLine
+	bv.br.BlockCurrentLine = bv.fset.Position(node.End()).Line - bv.fset.Position(node.Pos()).Line
+
+	if !bv.br.Select.matches(bv.br) {
+		return false
+	}
+
+	err := bv.f(bv.br, 0, value, false)
+	if err != nil {
+		bv.br.ErrorBlocks++
+		bv.br.Log.Errorf("block %d @ %s:%d failed to process with: %v", bv.br.BlockCount, bv.br.FileName, bv.fset.Position(node.Pos()).Line, err)
+	}
+
+	return false
+}
+
+// leave is called after a node's children have been visited
+func (bv blockVisitor) leave(cursor *astutil.Cursor) bool {
+	if _, ok := cursor.Node().(*ast.FuncDecl); ok {
+		bv.br.currentFunc = ""
+	}
+
+	return true
+}
+
+// funcDeclName returns the name of a function, or Type.Method for a method
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+
+	recv := fd.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + fd.Name.Name
+		}
+
+		return fd.Name.Name
+	}
+}
+
+// stringExprValue returns the unquoted value of a Go string literal, or of a chain of string
+// literals joined with + (e.g. "resource ... {\n" + "}"), along with the quote character of its
+// first literal. ok is false for any other expression, including chains that mix in non-literals.
+func stringExprValue(expr ast.Expr) (value, quoteChar string, ok bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", "", false
+		}
+
+		unquoted, err := strconv.Unquote(e.Value)
+		if err != nil {
+			return "", "", false
+		}
+
+		return unquoted, e.Value[0:1], true
+
+	case *ast.ParenExpr:
+		return stringExprValue(e.X)
+
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", "", false
+		}
+
+		left, quoteChar, ok := stringExprValue(e.X)
+		if !ok {
+			return "", "", false
+		}
+
+		right, _, ok := stringExprValue(e.Y)
+		if !ok {
+			return "", "", false
+		}
+
+		return left + right, quoteChar, true
+	}
+
+	return "", "", false
+}
+
+// stringExprOffsets returns the offset in the source of each byte of the value of a string
+// expression accepted by stringExprValue, escape sequences map all of their bytes to their start
+func stringExprOffsets(expr ast.Expr, file *token.File) []int {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		start := file.Offset(e.Pos()) + 1 // after the opening quote
+		body := e.Value[1 : len(e.Value)-1]
+		offsets := make([]int, 0, len(body))
+
+		if e.Value[0] == '`' {
+			for i := range len(body) {
+				// carriage returns are removed from raw string literals
+				if body[i] != '\r' {
+					offsets = append(offsets, start+i)
 				}
+			}
+
+			return offsets
+		}
 
-				return false
+		for i := 0; i < len(body); {
+			r, multibyte, tail, err := strconv.UnquoteChar(body[i:], '"')
+			if err != nil {
+				break
+			}
+			// \x and octal escapes are a single byte, any other rune is written as UTF-8. UnquoteChar
+			// only documents multibyte for runes that need it, so \u and \U escapes are checked too
+			n := 1
+			if multibyte || r < utf8.RuneSelf || body[i+1] == 'u' || body[i+1] == 'U' {
+				n = utf8.RuneLen(r)
+			}
+			for range n {
+				offsets = append(offsets, start+i)
 			}
+			i = len(body) - len(tail)
 		}
+
+		return offsets
+
+	case *ast.ParenExpr:
+		return stringExprOffsets(e.X, file)
+
+	case *ast.BinaryExpr:
+		return append(stringExprOffsets(e.X, file), stringExprOffsets(e.Y, file)...)
 	}
 
-	return true
+	return nil
 }
 
-// Includes matching Go format verbs in the resource, data source, list, ephemeral, action, variable, or output name.
-// Technically, this is only valid for the Go matcher, but included generally for simplicity.
-var terraformMatcher = regexp.MustCompile(`(((resource|data|list|ephemeral|action)\s+"[-a-z0-9_]+")|(variable|output))\s+"[-a-zA-Z0-9_%\[\]]+"\s+\{`)
+// BlockPosition returns the position in the host source of the byte at offset in the current block's
+// text, and the line of the host source it is on without its line ending, e.g. to point at the
+// position of a parse error.
+func (br *Reader) BlockPosition(offset int) (token.Position, string) {
+	offset = max(0, min(offset, len(br.currentText)))
+
+	if br.currentOffsets != nil {
+		srcOffset := br.currentEnd.Offset - 1 // the end of the text is the closing quote
+		if offset < len(br.currentOffsets) {
+			srcOffset = br.currentOffsets[offset]
+		}
+		pos := br.goFile.Position(br.goFile.Pos(srcOffset))
+		pos.Filename = br.FileName
+
+		lineStart := bytes.LastIndexByte(br.goSrc[:srcOffset], '\n') + 1
+		lineEnd := len(br.goSrc)
+		if i := bytes.IndexByte(br.goSrc[srcOffset:], '\n'); i >= 0 {
+			lineEnd = srcOffset + i
+		}
+
+		return pos, strings.TrimSuffix(string(br.goSrc[lineStart:lineEnd]), "\r")
+	}
+
+	before := br.currentText[:offset]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	lineEnd := len(br.currentText)
+	if i := strings.IndexByte(br.currentText[offset:], '\n'); i >= 0 {
+		lineEnd = offset + i
+	}
+
+	pos := token.Position{
+		Filename: br.FileName,
+		Offset:   br.currentStart.Offset + offset,
+		Line:     br.currentStart.Line + strings.Count(before, "\n"),
+		Column:   offset - lineStart + 1,
+	}
+	if lineStart == 0 {
+		pos.Column += br.currentStart.Column - 1
+	}
+
+	return pos, br.currentText[lineStart:lineEnd]
+}
+
+// Matches the opening line of any top-level terraform block, with the number of labels each kind takes:
+//   - two labels: resource, data, list, ephemeral, action
+//   - one label: variable, output, provider, module, check
+//   - no labels: terraform, locals, import, moved, removed (anchored to the start of a line, as
+//     without a label there is little else to tell them apart from arbitrary text)
+//
+// Includes matching Go format verbs in the block name, quoted or a bare %q or %v verb with any flags,
+// width, and argument indexes. Technically, this is only valid for the Go matcher, but included
+// generally for simplicity.
+var terraformMatcher = regexp.MustCompile(`(((resource|data|list|ephemeral|action)\s+"[-a-z0-9_]+")|(variable|output|provider|module|check))\s+("[-a-zA-Z0-9_%\[\]+#.*]+"|%[-+# 0-9.*\[\]]*[qv])\s+\{|(?m:^[ \t]*(terraform|locals|import|moved|removed)[ \t]*\{)`)
 
 // A simple check to see if the content looks like a Terraform configuration.
-// Looks for a line with either a resource, data source, list, ephemeral, action, variable, or output declaration
+// Looks for a line opening any of the top-level terraform blocks, e.g. a resource, provider, or terraform block
 func looksLikeTerraform(s string) bool {
 	return terraformMatcher.MatchString(s)
 }
 
+// GoStringLiteral returns s as a Go string literal that uses the same quoting as the
+// original literal: raw (backtick) literals are written as is, while interpreted literals
+// are re-escaped so any newlines and quotes in s still produce valid Go.
+func GoStringLiteral(quoteChar, s string) string {
+	if quoteChar == "`" && !strings.Contains(s, "`") {
+		return "`" + s + "`"
+	}
+
+	return strconv.Quote(s)
+}
+
 func (br *Reader) DoTheThing(fs afero.Fs, filename string, stdin io.Reader, stdout io.Writer) error {
 	inStream := &bytes.Buffer{}
 
 	if filename != "" {
+		if IsHCLFile(filename) {
+			return br.doTheThingHCL(fs, filename)
+		}
+
 		if !strings.HasSuffix(filename, ".go") {
 			return br.doTheThingPatternMatch(fs, filename, stdin, stdout)
 		}
 	} else {
 		tee := io.TeeReader(stdin, inStream)
 		teee := bufio.NewReader(tee)
-		if matched, err := regexp.MatchReader(`package [a-zA-Z0-9_]+\n`, teee); err != nil {
+		matched, err := regexp.MatchReader(`package [a-zA-Z0-9_]+\n`, teee)
+		if err != nil {
 			return err
-		} else if !matched {
-			return br.doTheThingPatternMatch(fs, filename, inStream, stdout)
 		}
-	}
 
-	buf := &strings.Builder{}
+		// the match only reads as far as it needs to, so the rest of stdin follows what it has read
+		stdin = io.MultiReader(inStream, stdin)
+		if !matched {
+			return br.doTheThingPatternMatch(fs, filename, stdin, stdout)
+		}
+	}
 
+	var original fileState
 	if filename != "" {
 		br.FileName = filename
 		br.Log.Debugf("opening src file %s", filename)
@@ -157,75 +408,127 @@ func (br *Reader) DoTheThing(fs afero.Fs, filename string, stdin io.Reader, stdo
 		}()
 		br.Reader = file
 
-		// for now write to buffer
-		if !br.ReadOnly {
-			br.Writer = buf
-		} else {
-			br.Writer = io.Discard
+		info, err := file.Stat()
+		if err != nil {
+			return err
 		}
+		original = newFileState(info)
 	} else {
 		br.FileName = "stdin"
-		br.Reader = inStream
-		br.Writer = stdout
+		br.Reader = stdin
+	}
 
-		if br.ReadOnly {
-			br.Writer = io.Discard
+	// blocks are replaced directly in the go source, nothing is written line by line
+	br.Writer = io.Discard
+
+	src, err := io.ReadAll(br.Reader)
+	if err != nil {
+		return err
+	}
+
+	if err := br.readGo(filename, src); err != nil {
+		return err
+	}
+
+	// If not read-only, need to write back to file.
+	if !br.ReadOnly {
+		if filename == "" {
+			_, err = stdout.Write(spliceEdits(src, br.goEdits))
+			return err
 		}
+
+		// leave files without any changes alone, so their modification time is kept
+		if len(br.goEdits) == 0 {
+			return nil
+		}
+
+		br.Log.Debugf("copying..")
+		return writeFile(fs, filename, original, bytes.NewReader(spliceEdits(src, br.goEdits)))
 	}
 
+	// todo should this be at the end of a command?
+	// fmt.Fprintf(os.Stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
+	return nil
+}
+
+// readGo hands the terraform blocks in the string literals of go source to BlockRead
+func (br *Reader) readGo(filename string, src []byte) error {
 	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, filename, br.Reader, parser.ParseComments)
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
 	if err != nil {
 		return err
 	}
+	br.goEdits = nil
+	br.goSrc = src
+	br.Source = src
+	br.Kind = KindGo
+	br.goFile = fset.File(f.Pos())
+	br.goFormatCalls = formatCallIndex(f, fset, src)
 	visitor := blockVisitor{
 		br:   br,
 		fset: fset,
 		f:    br.BlockRead,
 	}
-	result := astutil.Apply(f, visitor.Visit, nil)
+	br.currentFunc = ""
+	astutil.Apply(f, visitor.Visit, visitor.leave)
 
 	br.LineCount = fset.Position(f.End()).Line // For summary line
-	if err := format.Node(buf, fset, result); err != nil {
-		return err
-	}
-
-	var destination io.Writer
-	var outfile afero.File
 
-	// If not read-only, need to write back to file.
-	if !br.ReadOnly {
-		if filename != "" {
-			outfile, err = fs.Create(filename)
-			if err != nil {
-				return err
-			}
-			destination = outfile
-		} else {
-			destination = stdout
-		}
+	return nil
+}
 
-		br.Log.Debugf("copying..")
-		_, err = io.WriteString(destination, buf.String())
+// sourceEdit replaces the source bytes [start, end) with text
+type sourceEdit struct {
+	start, end int
+	text       string
+}
 
-		// a failed close on a just-written file can mean lost data, so it takes over err (unless
-		// the write already failed, in which case that error wins)
-		if outfile != nil {
-			if closeErr := outfile.Close(); err == nil {
-				err = closeErr
-			}
-		}
+// ReplaceCurrentNode replaces the go string expression of the current block with literal. Only the
+// bytes of the expression change, the rest of the file is left exactly as it was. A chain of literals
+// joined with + is replaced as a whole, see ReplaceCurrentString to keep them.
+func (br *Reader) ReplaceCurrentNode(literal string) {
+	br.goEdits = append(br.goEdits, sourceEdit{
+		start: br.currentStart.Offset,
+		end:   br.currentEnd.Offset,
+		text:  literal,
+	})
+}
 
+// ReplaceCurrentString replaces the value of the go string expression of the current block, its text
+// with the padding around it, with value. Each of its string literals keeps its place and quoting, the
+// literals of a + chain are each given the lines of value that replace their own, and it is an error
+// for a chain not to be split at line ends (ReplaceCurrentNode can replace it with a single literal).
+func (br *Reader) ReplaceCurrentString(value string) error {
+	edits, err := literalEdits(br.currentLiterals, value)
+	if err != nil {
 		return err
 	}
+	br.goEdits = append(br.goEdits, edits...)
 
-	// todo should this be at the end of a command?
-	// fmt.Fprintf(os.Stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
 	return nil
 }
 
+// spliceEdits applies edits, which must not overlap, to src
+func spliceEdits(src []byte, edits []sourceEdit) []byte {
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b sourceEdit) int {
+		return a.start - b.start
+	})
+
+	out := make([]byte, 0, len(src))
+	last := 0
+	for _, e := range edits {
+		out = append(out, src[last:e.start]...)
+		out = append(out, e.text...)
+		last = e.end
+	}
+
+	return append(out, src[last:]...)
+}
+
 func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.Reader, stdout io.Writer) error {
 	var buf *bytes.Buffer
+	var original fileState
 
 	if filename != "" {
 		br.FileName = filename
@@ -241,8 +544,13 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 		}()
 		br.Reader = file
 
-		// for now write to buffer
+		// for now write to buffer, keeping the original to compare against
 		if !br.ReadOnly {
+			info, err := file.Stat()
+			if err != nil {
+				return err
+			}
+			original = newFileState(info)
 			buf = bytes.NewBuffer([]byte{})
 			br.Writer = buf
 		} else {
@@ -258,17 +566,78 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 		}
 	}
 
-	var textFmt textFormat
-	switch filepath.Ext(filename) {
-	case ".rst":
-		textFmt = restructuredTextFormat{}
-	default:
-		textFmt = markdownTextFormat{}
+	src, err := io.ReadAll(br.Reader)
+	if err != nil {
+		return err
+	}
+	br.Source = src
+	br.Kind = Kind(textFormatNameForFile(filename))
+
+	if err := br.readText(bytes.NewReader(src), TextFormatForFile(filename)); err != nil {
+		return err
+	}
+
+	// If not read-only, need to write back to file. Files without any changes are left alone, so
+	// their modification time is kept
+	if !br.ReadOnly && filename != "" && !bytes.Equal(buf.Bytes(), src) {
+		br.Log.Debugf("copying..")
+		return writeFile(fs, filename, original, buf)
+	}
+
+	// todo should this be at the end of a command?
+	// fmt.Fprintf(os.Stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
+	return nil
+}
+
+// readText hands the terraform blocks textFmt finds in a host document to BlockRead, and every other
+// line to LineRead
+func (br *Reader) readText(r io.Reader, textFmt TextFormat) error {
+	headerFmt, hasHeaders := textFmt.(HeaderTextFormat)
+	openEndedFmt, ok := textFmt.(OpenEndedTextFormat)
+	openEnded := ok && openEndedFmt.IsOpenEnded()
+	languageFmt, hasLanguage := textFmt.(LanguageTextFormat)
+
+	// the byte offset each line starts at, lineStarts[n-1] for line n, and the offset after the last
+	// line read so far. Lines are scanned without their line endings, so these come from the split
+	lineStarts := []int{}
+	offset := 0
+	lineStart := func(line int) int {
+		if line-1 < len(lineStarts) {
+			return lineStarts[line-1]
+		}
+
+		return offset
+	}
+
+	// readBlock records where a block whose content starts on contentLine is before handing it on
+	readBlock := func(block string, contentLine int, host HostContext, preserveIndent bool) error {
+		endLine := contentLine + strings.Count(block, "\n")
+		br.currentStart = token.Position{Filename: br.FileName, Offset: lineStart(contentLine), Line: contentLine, Column: 1}
+		br.currentEnd = token.Position{Filename: br.FileName, Offset: lineStart(endLine), Line: endLine, Column: 1}
+		host.Indented = preserveIndent
+		br.currentHost = host
+		br.currentText = block
+		br.currentOffsets = nil
+		br.currentLanguage = ""
+		if hasLanguage {
+			br.currentLanguage = languageFmt.Language()
+		}
+
+		return br.readBlock(block, preserveIndent)
 	}
 
 	br.LineCount = 0
 	br.BlockCount = 0
-	s := bufio.NewScanner(br.Reader)
+	s := bufio.NewScanner(r)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, line, err := bufio.ScanLines(data, atEOF)
+		if line != nil {
+			lineStarts = append(lineStarts, offset)
+			offset += advance
+		}
+
+		return advance, line, err
+	})
 	for s.Scan() { // scan file
 		br.LineCount++
 		l := s.Text() + "\n"
@@ -277,7 +646,8 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 			return fmt.Errorf("NB LineRead failed @ %s:%d for %s: %w", br.FileName, br.LineCount, l, err)
 		}
 
-		if textFmt.isStartingLine(l) {
+		// the finish line of an open ended block can start the next block straight away, so loop
+		for l != "" && textFmt.IsStartingLine(l) {
 			block := ""
 			br.BlockCurrentLine = 0
 			br.BlockCount++
@@ -285,14 +655,28 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 			// an indented fence (e.g. inside a markdown list item) means the block content is
 			// indented too; preserve that indentation when formatting (issue #51)
 			fenceIndented := strings.TrimLeft(l, " \t") != l
+			host := HostContext{Opening: strings.TrimSuffix(l, "\n")}
+			contentLine := br.LineCount + 1
+			l = ""
+			finished := false
 
 			for s.Scan() { // scan block
 				br.LineCount++
 				br.BlockCurrentLine++
 				l2 := s.Text() + "\n"
 
+				// header lines belong to the host document, e.g. rst directive options
+				if block == "" && hasHeaders && headerFmt.IsHeaderLine(l2) {
+					if err := br.LineRead(br, br.LineCount, l2); err != nil {
+						return fmt.Errorf("NB LineRead failed @ %s:%d for %s: %w", br.FileName, br.LineCount, l2, err)
+					}
+					contentLine = br.LineCount + 1
+
+					continue
+				}
+
 				// make sure we don't run into another block
-				if textFmt.isStartingLine(l2) {
+				if textFmt.IsStartingLine(l2) {
 					// the end of current block must be malformed, so lets pass it through and log an error
 					br.Log.Errorf("block %d @ %s:%d failed to find end of block", br.BlockCount, br.FileName, br.LineCount-br.BlockCurrentLine)
 					if err := ReaderPassthrough(br, br.LineCount, block); err != nil { // is this ok or should we loop with LineRead?
@@ -306,27 +690,24 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 					block = ""
 					br.BlockCount++
 					fenceIndented = strings.TrimLeft(l2, " \t") != l2
+					host = HostContext{Opening: strings.TrimSuffix(l2, "\n")}
+					contentLine = br.LineCount + 1
 
 					continue
 				}
 
-				if textFmt.isFinishLine(l2) {
+				if textFmt.IsFinishLine(l2) {
 					// stripping the finish line's leading whitespace would break the layout of an
 					// intentionally indented block, so leave those alone
 					if br.FixFinishLines && !fenceIndented {
 						l2 = lineWithLeadingSpacesMatcher.ReplaceAllString(l2, `$1`)
 					}
 
-					br.LinesBlock += br.BlockCurrentLine
-
-					// todo configure this behaviour with switch's
-					if err := br.BlockRead(br, br.LineCount, block, textFmt.preserveIndentation() || fenceIndented); err != nil {
-						// for now ignore block errors and output unformatted
-						br.ErrorBlocks++
-						br.Log.Errorf("block %d @ %s:%d failed to process with: %v", br.BlockCount, br.FileName, br.LineCount-br.BlockCurrentLine, err)
-						if err := ReaderPassthrough(br, br.LineCount, block); err != nil {
-							return err
-						}
+					if !openEnded {
+						host.Closing = strings.TrimSuffix(l2, "\n")
+					}
+					if err := readBlock(block, contentLine, host, textFmt.PreserveIndentation() || fenceIndented); err != nil {
+						return err
 					}
 
 					if err := br.LineRead(br, br.LineCount, l2); err != nil {
@@ -334,12 +715,25 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 					}
 
 					block = ""
+					finished = true
+					if openEnded {
+						l = l2
+					}
 
 					break
 				}
 				block += l2
 			}
 
+			// an open ended block is also finished by the end of the file
+			if !finished && openEnded && block != "" {
+				if err := readBlock(block, contentLine, host, textFmt.PreserveIndentation() || fenceIndented); err != nil {
+					return err
+				}
+
+				block = ""
+			}
+
 			// ensure last block in the file was property handled
 			if block != "" {
 				// for each line { Lineread()?
@@ -353,26 +747,27 @@ func (br *Reader) doTheThingPatternMatch(fs afero.Fs, filename string, stdin io.
 		}
 	}
 
-	// If not read-only, need to write back to file.
-	if !br.ReadOnly && filename != "" {
-		destination, err := fs.Create(filename)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		br.Log.Debugf("copying..")
-		_, err = io.Copy(destination, buf)
+// readBlock hands a block found by doTheThingPatternMatch to BlockRead, a block that fails to process is
+// logged and passed through unformatted
+func (br *Reader) readBlock(block string, preserveIndent bool) error {
+	br.LinesBlock += br.BlockCurrentLine
 
-		// a failed close on a just-written file can mean lost data, so it takes over err (unless
-		// the write already failed, in which case that error wins)
-		if closeErr := destination.Close(); err == nil {
-			err = closeErr
-		}
+	if !br.Select.matches(br) {
+		return ReaderPassthrough(br, br.LineCount, block)
+	}
 
-		return err
+	// todo configure this behaviour with switch's
+	if err := br.BlockRead(br, br.LineCount, block, preserveIndent); err != nil {
+		// for now ignore block errors and output unformatted
+		br.ErrorBlocks++
+		br.Log.Errorf("block %d @ %s:%d failed to process with: %v", br.BlockCount, br.FileName, br.LineCount-br.BlockCurrentLine, err)
+		if err := ReaderPassthrough(br, br.LineCount, block); err != nil {
+			return err
+		}
 	}
 
-	// todo should this be at the end of a command?
-	// fmt.Fprintf(os.Stderr, c.Sprintf("\nFinished processing <cyan>%d</> lines <yellow>%d</> blocks!\n", br.LineCount, br.BlockCount))
 	return nil
 }
@@ -2,6 +2,9 @@ package blocks
 
 import (
 	"bytes"
+	"go/parser"
+	"go/token"
+	"slices"
 	"testing"
 
 	"github.com/katbyte/terrafmt/lib/common"
@@ -111,6 +114,22 @@ func TestBlockDetection(t *testing.T) {
 					text: `resource "azurerm_storage_container" "UpperCase" {
   name = "tf-test-container-with-uppercase"
 }
+`,
+				},
+				{
+					leadingPadding:  "\n",
+					trailingPadding: "\n",
+					text: `provider "azurerm" {
+  features {}
+}
+`,
+				},
+				{
+					leadingPadding:  "\n",
+					trailingPadding: "\n",
+					text: `terraform {
+  required_version = ">= 1.0"
+}
 `,
 				},
 			},
@@ -259,6 +278,113 @@ func TestBlockDetection(t *testing.T) {
     }
   }
 
+`,
+				},
+			},
+		},
+		{
+			sourcefile: "testdata/test5.md",
+			expectedBlocks: []block{
+				{
+					text: `resource "azurerm_storage_container" "tilde" {
+  name = "tf-test-container-tilde"
+}
+`,
+				},
+				{
+					text: `resource "azurerm_storage_container" "info-string" {
+  name = "tf-test-container-info-string"
+}
+`,
+				},
+				{
+					text: "resource \"azurerm_storage_container\" \"long-fence\" {\n" +
+						"  description = <<EOT\n" +
+						"```\n" +
+						"not the end of the block\n" +
+						"```\n" +
+						"EOT\n" +
+						"}\n",
+				},
+				{
+					text: "resource \"azurerm_storage_container\" \"mixed-fence\" {\n" +
+						"  name = \"tf-test-container-mixed-fence\"\n" +
+						"}\n" +
+						"```\n",
+				},
+				{
+					text: "resource \"azurerm_storage_container\" \"info-close\" {\n" +
+						"  name = \"tf-test-container-info-close\"\n" +
+						"}\n" +
+						"```hcl\n",
+				},
+			},
+		},
+		{
+			sourcefile: "testdata/test6.rst",
+			expectedBlocks: []block{
+				{
+					text: `   resource "azurerm_storage_container" "options" {
+     name = "tf-test-container-options"
+   }
+
+`,
+				},
+				{
+					text: `   resource "azurerm_storage_container" "sourcecode" {
+     name = "tf-test-container-sourcecode"
+   }
+
+`,
+				},
+				{
+					text: `   resource "azurerm_storage_container" "consecutive" {
+     name = "tf-test-container-consecutive"
+   }
+
+`,
+				},
+				{
+					text: `     resource "azurerm_storage_container" "list" {
+       name = "tf-test-container-list"
+     }
+
+`,
+				},
+				{
+					text: `      resource "azurerm_storage_container" "admonition" {
+        name = "tf-test-container-admonition"
+      }
+
+`,
+				},
+				{
+					text: `   resource "azurerm_storage_container" "eof" {
+     name = "tf-test-container-eof"
+   }
+`,
+				},
+			},
+		},
+		{
+			sourcefile: "testdata/test4.adoc",
+			expectedBlocks: []block{
+				{
+					text: `resource "azurerm_storage_container" "terraform" {
+  name = "tf-test-container-terraform"
+}
+`,
+				},
+				{
+					text: `resource "azurerm_storage_container" "hcl" {
+  name = "tf-test-container-hcl"
+}
+`,
+				},
+				{
+					text: `resource "azurerm_storage_container" "long-delimiter" {
+  name = "tf-test-container-long-delimiter"
+}
 `,
 				},
 			},
@@ -387,27 +513,128 @@ resource "azurerm_storage_container" "%s" {
 }`,
 			expected: true,
 		},
-		// 		{
-		// 			text: `
-		// resource "azurerm_storage_container" "%[1]s" {
-		//   name = "tf-test-container-simple"
-		// }`,
-		// 			expected: true,
-		// 		},
-		// 		{
-		// 			text: `
-		// resource "azurerm_storage_container" %q {
-		//   name = "tf-test-container-simple"
-		// }`,
-		// 			expected: true,
-		// 		},
-		// 		{
-		// 			text: `
-		// resource "azurerm_storage_container" %[1]q {
-		//   name = "tf-test-container-simple"
-		// }`,
-		// 			expected: true,
-		// 		},
+		{
+			text: `
+provider "azurerm" {
+  features {}
+}`,
+			expected: true,
+		},
+		{
+			text: `
+module "network" {
+  source = "./modules/network"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+check "health" {
+  assert {
+    condition     = true
+    error_message = "unhealthy"
+  }
+}`,
+			expected: true,
+		},
+		{
+			text: `
+terraform {
+  required_providers {
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+  }
+}`,
+			expected: true,
+		},
+		{
+			text: `
+locals {
+  name = "example"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+import {
+  to = azurerm_resource_group.example
+  id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/example"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+moved {
+  from = azurerm_resource_group.old
+  to   = azurerm_resource_group.new
+}`,
+			expected: true,
+		},
+		{
+			text: `
+  removed {
+    from = azurerm_resource_group.example
+  }`,
+			expected: true,
+		},
+		{
+			text: `
+provider "azurerm" "extra" {
+}`,
+			expected: false,
+		},
+		{
+			text: `
+resource "azurerm_resource_group" {
+}`,
+			expected: false,
+		},
+		{
+			text: `
+terraform "label" {
+}`,
+			expected: false,
+		},
+		{
+			text:     "failed to import { id = %s }",
+			expected: false,
+		},
+		{
+			text: `
+resource "azurerm_storage_container" "%[1]s" {
+  name = "tf-test-container-simple"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+resource "azurerm_storage_container" %q {
+  name = "tf-test-container-simple"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+resource "azurerm_storage_container" %[1]q {
+  name = "tf-test-container-simple"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+resource "azurerm_storage_container" %+v {
+  name = "tf-test-container-simple"
+}`,
+			expected: true,
+		},
+		{
+			text: `
+resource "azurerm_storage_container" "test-%-10s" {
+  name = "tf-test-container-simple"
+}`,
+			expected: true,
+		},
 		{
 			text:     "%d: bad create: \n%#v\n%#v",
 			expected: false,
@@ -443,3 +670,74 @@ resource "azurerm_storage_container" "%s" {
 		}
 	}
 }
+
+func TestGoStringLiteral(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		quoteChar string
+		text      string
+		expected  string
+	}{
+		{
+			quoteChar: "`",
+			text:      "\nresource \"a\" \"b\" {\n}\n",
+			expected:  "`\nresource \"a\" \"b\" {\n}\n`",
+		},
+		{
+			quoteChar: `"`,
+			text:      "\nresource \"a\" \"b\" {\n  name = \"c\\d\"\n}\n",
+			expected:  `"\nresource \"a\" \"b\" {\n  name = \"c\\d\"\n}\n"`,
+		},
+		{
+			quoteChar: "`",
+			text:      "resource \"a\" \"b\" {\n  name = \"`\"\n}\n",
+			expected:  `"resource \"a\" \"b\" {\n  name = \"` + "`" + `\"\n}\n"`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		actual := GoStringLiteral(testcase.quoteChar, testcase.text)
+		if actual != testcase.expected {
+			t.Errorf("Expected %s, got %s", testcase.expected, actual)
+		}
+	}
+}
+
+func TestStringExprOffsets(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		expr     string
+		expected []int // the offset of each byte of the value in expr
+	}{
+		{
+			expr:     `"aéb"`,
+			expected: []int{1, 2, 2, 4},
+		},
+		{
+			expr:     `"\U0001F600."`,
+			expected: []int{1, 1, 1, 1, 11},
+		},
+		{
+			expr:     `"\xe9\351."`,
+			expected: []int{1, 5, 9},
+		},
+		{
+			expr:     `"é" + ` + "`\\n`",
+			expected: []int{1, 1, 8, 9},
+		},
+	}
+
+	for _, testcase := range testcases {
+		fset := token.NewFileSet()
+		expr, err := parser.ParseExprFrom(fset, "", testcase.expr, 0)
+		if err != nil {
+			t.Fatalf("Error parsing %s: %s", testcase.expr, err)
+		}
+
+		value, _, _ := stringExprValue(expr)
+		actual := stringExprOffsets(expr, fset.File(expr.Pos()))
+		if len(actual) != len(value) || !slices.Equal(actual, testcase.expected) {
+			t.Errorf("Expected offsets %v for %s (%d bytes), got %v", testcase.expected, testcase.expr, len(value), actual)
+		}
+	}
+}
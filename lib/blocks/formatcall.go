@@ -0,0 +1,164 @@
+package blocks
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// formatFuncs are the fmt functions whose format a go block can be, and the index of the format in
+// their arguments
+var formatFuncs = map[string]int{
+	"Sprintf": 0,
+	"Errorf":  0,
+	"Printf":  0,
+	"Fprintf": 1,
+}
+
+// FormatCall is a call of a fmt function (fmt.Sprintf, fmt.Errorf, ...) whose format is a go block,
+// either the string expression itself or a const it is the value of.
+type FormatCall struct {
+	Func  string // e.g. fmt.Sprintf
+	Const string // the const the call uses as its format, empty when it is the block's expression
+	Pos   token.Position
+
+	// Args are the arguments after the format, with the position and source of each
+	Args    []ast.Expr
+	ArgPos  []token.Position
+	ArgText []string
+	Spreads bool // the last argument is spread with ..., so the number of arguments is not known
+}
+
+// formatCallIndex finds the fmt calls in a go file whose format is a string expression, directly or
+// through a const declared once in the file, keyed by the expression
+func formatCallIndex(f *ast.File, fset *token.FileSet, src []byte) map[ast.Expr][]FormatCall {
+	fmtName := ""
+	for _, imp := range f.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err != nil || path != "fmt" {
+			continue
+		}
+
+		fmtName = "fmt"
+		if imp.Name != nil {
+			fmtName = imp.Name.Name
+		}
+	}
+	if fmtName == "" || fmtName == "_" || fmtName == "." {
+		return nil
+	}
+
+	// consts declared more than once (in different scopes) can not be told apart by name, so are left out
+	consts := map[string]ast.Expr{}
+	declared := map[string]int{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		decl, ok := n.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			return true
+		}
+
+		for _, spec := range decl.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range vs.Names {
+				declared[name.Name]++
+				if len(vs.Values) == len(vs.Names) {
+					consts[name.Name] = vs.Values[i]
+				}
+			}
+		}
+
+		return true
+	})
+
+	calls := map[ast.Expr][]FormatCall{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok || pkg.Name != fmtName {
+			return true
+		}
+		formatArg, ok := formatFuncs[sel.Sel.Name]
+		if !ok || len(call.Args) <= formatArg {
+			return true
+		}
+
+		fc := FormatCall{
+			Func:    "fmt." + sel.Sel.Name,
+			Pos:     fset.Position(call.Pos()),
+			Args:    call.Args[formatArg+1:],
+			Spreads: call.Ellipsis.IsValid(),
+		}
+		for _, arg := range fc.Args {
+			start, end := fset.Position(arg.Pos()), fset.Position(arg.End())
+			fc.ArgPos = append(fc.ArgPos, start)
+			fc.ArgText = append(fc.ArgText, string(src[start.Offset:end.Offset]))
+		}
+
+		format := call.Args[formatArg]
+		if ident, ok := ast.Unparen(format).(*ast.Ident); ok {
+			value, ok := consts[ident.Name]
+			if !ok || declared[ident.Name] != 1 {
+				return true
+			}
+			fc.Const = ident.Name
+			format = value
+		}
+		calls[format] = append(calls[format], fc)
+
+		return true
+	})
+
+	return calls
+}
+
+// CurrentFormatCalls returns the fmt calls whose format is the current go block, directly or through
+// a const. It is empty for blocks that are not in go source, and for go blocks whose calls can not be
+// found statically, e.g. a format passed to a helper function.
+func (br *Reader) CurrentFormatCalls() []FormatCall {
+	if br.Kind != KindGo || br.CurrentNodeCursor == nil {
+		return nil
+	}
+
+	node, ok := br.CurrentNodeCursor.Node().(ast.Expr)
+	if !ok {
+		return nil
+	}
+
+	return br.goFormatCalls[node]
+}
+
+// ReplaceFormatArgs replaces the arguments after the format of call, one of the current block's
+// CurrentFormatCalls, with args, the source of each argument. The arguments are separated the way
+// the first two were, so arguments on lines of their own stay that way. Like ReplaceCurrentNode only
+// the bytes of the arguments change.
+func (br *Reader) ReplaceFormatArgs(call FormatCall, args []string) {
+	if len(call.Args) == 0 {
+		return
+	}
+
+	last := len(call.Args) - 1
+	start := call.ArgPos[0].Offset
+	end := call.ArgPos[last].Offset + len(call.ArgText[last])
+
+	sep := ", "
+	if len(call.Args) > 1 {
+		sep = string(br.goSrc[start+len(call.ArgText[0]) : call.ArgPos[1].Offset])
+	}
+
+	br.goEdits = append(br.goEdits, sourceEdit{
+		start: start,
+		end:   end,
+		text:  strings.Join(args, sep),
+	})
+}
@@ -0,0 +1,58 @@
+package blocks
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+func TestCurrentFormatCalls(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport f \"fmt\"\n\n" +
+		"const shared = `\nresource \"a\" \"b\" {\n  name = %q\n}\n`\n\n" +
+		"func testAccA() string {\n\treturn f.Sprintf(shared, \"a\") + f.Sprintf(shared, \"b\")\n}\n\n" +
+		"func testAccB(w io.Writer) {\n\tf.Fprintf(w, (`\nresource \"a\" \"b\" {\n  name = %q\n}\n`), \"c\", \"d\")\n}\n\n" +
+		"func testAccC() string {\n\treturn fmt.Sprintf(`\nresource \"a\" \"b\" {\n  name = %q\n}\n`, \"e\")\n}\n"
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "a_test.go", []byte(src), 0o644); err != nil {
+		t.Fatalf("Error writing a_test.go: %s", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	var got [][]FormatCall
+	br := Reader{
+		Log:      log,
+		ReadOnly: true,
+		LineRead: ReaderIgnore,
+		BlockRead: func(br *Reader, _ int, _ string, _ bool) error {
+			got = append(got, br.CurrentFormatCalls())
+
+			return nil
+		},
+	}
+	if err := br.DoTheThing(fs, "a_test.go", nil, io.Discard); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(got))
+	}
+
+	// the const is the format of both calls
+	if len(got[0]) != 2 || got[0][0].Const != "shared" || got[0][1].Const != "shared" || got[0][0].Func != "fmt.Sprintf" {
+		t.Errorf("Expected the const block to be the format of two fmt.Sprintf calls, got %+v", got[0])
+	}
+	if len(got[1]) != 1 || got[1][0].Func != "fmt.Fprintf" || len(got[1][0].Args) != 2 || got[1][0].ArgPos[0].Line != 20 {
+		t.Errorf("Expected the block to be the format of a fmt.Fprintf call with 2 arguments, got %+v", got[1])
+	}
+	// fmt is imported as f, so fmt.Sprintf is some other package
+	if len(got[2]) != 0 {
+		t.Errorf("Expected the block not to be the format of a fmt call, got %+v", got[2])
+	}
+}
@@ -0,0 +1,93 @@
+package blocks
+
+import (
+	"bytes"
+	"go/token"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// hclFileExtensions are the extensions of terraform and other HCL files, these are formatted as a whole
+// instead of having blocks extracted from them
+var hclFileExtensions = []string{".tf", ".tfvars", ".hcl"}
+
+// IsHCLFile reports whether filename is a terraform or other HCL file that is formatted as a whole.
+func IsHCLFile(filename string) bool {
+	return slices.Contains(hclFileExtensions, strings.ToLower(filepath.Ext(filename)))
+}
+
+// doTheThingHCL hands the whole of a HCL file to BlockRead as a single block starting on line 1
+func (br *Reader) doTheThingHCL(fs afero.Fs, filename string) error {
+	br.FileName = filename
+	br.Log.Debugf("opening src file %s", filename)
+	info, err := fs.Stat(filename)
+	if err != nil {
+		return err
+	}
+	original := newFileState(info)
+	src, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return err
+	}
+
+	var buf *bytes.Buffer
+	if !br.ReadOnly {
+		buf = &bytes.Buffer{}
+		br.Writer = buf
+	} else {
+		br.Writer = io.Discard
+	}
+
+	if err := br.readHCL(src); err != nil {
+		return err
+	}
+
+	// If not read-only, need to write back to file. Files without any changes are left alone, so
+	// their modification time is kept
+	if !br.ReadOnly && !bytes.Equal(buf.Bytes(), src) {
+		br.Log.Debugf("copying..")
+		return writeFile(fs, filename, original, buf)
+	}
+
+	return nil
+}
+
+// readHCL hands the whole of a HCL file's source to BlockRead as a single block starting on line 1
+func (br *Reader) readHCL(src []byte) error {
+	br.Source = src
+	br.Kind = KindHCL
+
+	content := string(src)
+	br.LineCount = strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		br.LineCount++
+	}
+	br.BlockCount = 1
+	br.BlockCurrentLine = br.LineCount - 1
+
+	br.currentStart = token.Position{Filename: br.FileName, Offset: 0, Line: 1, Column: 1}
+	br.currentEnd = positionAt(br.FileName, src, len(src))
+	br.currentHost = HostContext{}
+	br.currentLanguage = "hcl"
+	br.currentText = content
+	br.currentOffsets = nil
+
+	return br.readBlock(content, false)
+}
+
+// positionAt returns the position of the byte at offset in src
+func positionAt(filename string, src []byte, offset int) token.Position {
+	before := src[:offset]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+
+	return token.Position{
+		Filename: filename,
+		Offset:   offset,
+		Line:     bytes.Count(before, []byte("\n")) + 1,
+		Column:   offset - lineStart + 1,
+	}
+}
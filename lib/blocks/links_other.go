@@ -0,0 +1,10 @@
+//go:build !unix
+
+package blocks
+
+import "os"
+
+// hardLinks returns the number of hard links to the file info describes, always 1 where it is not known
+func hardLinks(os.FileInfo) uint64 {
+	return 1
+}
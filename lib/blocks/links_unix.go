@@ -0,0 +1,17 @@
+//go:build unix
+
+package blocks
+
+import (
+	"os"
+	"syscall"
+)
+
+// hardLinks returns the number of hard links to the file info describes, 1 when it is not known
+func hardLinks(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink) //nolint:unconvert // Nlink is narrower on some platforms
+	}
+
+	return 1
+}
@@ -0,0 +1,114 @@
+package blocks
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/kylelemons/godebug/diff"
+)
+
+// goLiteral is one of the string literals the value of a go block is read from, a chain of literals
+// joined with + has one for each
+type goLiteral struct {
+	start, end int    // the source offsets of the literal, including its quotes
+	quote      string // its quote character
+	value      string // its unquoted value
+}
+
+// stringExprLiterals returns the literals of a string expression accepted by stringExprValue, in order
+func stringExprLiterals(expr ast.Expr, file *token.File) []goLiteral {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		value, err := strconv.Unquote(e.Value)
+		if err != nil {
+			return nil
+		}
+
+		return []goLiteral{{
+			start: file.Offset(e.Pos()),
+			end:   file.Offset(e.End()),
+			quote: e.Value[0:1],
+			value: value,
+		}}
+
+	case *ast.ParenExpr:
+		return stringExprLiterals(e.X, file)
+
+	case *ast.BinaryExpr:
+		return append(stringExprLiterals(e.X, file), stringExprLiterals(e.Y, file)...)
+	}
+
+	return nil
+}
+
+// literalEdits returns the source edits that give the literals of a go block the joined value, each
+// keeping its place and quoting. The literals of a + chain are rewritten one by one: each is given
+// the lines of value that are the same as, or replace, its own lines, so a chain must be split at line
+// ends for its lines to have a single literal each.
+func literalEdits(literals []goLiteral, value string) ([]sourceEdit, error) {
+	if len(literals) == 1 {
+		l := literals[0]
+		if value == l.value {
+			return nil, nil
+		}
+
+		return []sourceEdit{{start: l.start, end: l.end, text: GoStringLiteral(l.quote, value)}}, nil
+	}
+
+	// the literal each line of the old value is in
+	var oldLines []string
+	var owners []int
+	for i, l := range literals {
+		if i < len(literals)-1 && !strings.HasSuffix(l.value, "\n") {
+			return nil, errors.New("its + chain of string literals is not split at line ends, so the literals can not be rewritten one by one")
+		}
+		for _, line := range splitLines(l.value) {
+			oldLines = append(oldLines, line)
+			owners = append(owners, i)
+		}
+	}
+
+	// changed lines go to the literals of the lines they replace, added ones to the literal before them
+	values := make([]strings.Builder, len(literals))
+	old := 0
+	for _, chunk := range diff.DiffChunks(oldLines, splitLines(value)) {
+		for i, line := range chunk.Added {
+			owner := 0
+			switch {
+			case len(chunk.Deleted) > 0:
+				owner = owners[old+min(i, len(chunk.Deleted)-1)]
+			case old > 0:
+				owner = owners[old-1]
+			}
+			values[owner].WriteString(line)
+		}
+		old += len(chunk.Deleted)
+
+		for _, line := range chunk.Equal {
+			values[owners[old]].WriteString(line)
+			old++
+		}
+	}
+
+	var edits []sourceEdit
+	for i, l := range literals {
+		if v := values[i].String(); v != l.value {
+			edits = append(edits, sourceEdit{start: l.start, end: l.end, text: GoStringLiteral(l.quote, v)})
+		}
+	}
+
+	return edits, nil
+}
+
+// splitLines splits s after each newline, a last line without one is kept
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
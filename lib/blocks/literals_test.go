@@ -0,0 +1,64 @@
+package blocks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteChain(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		src      string
+		rewrite  func(string) string
+		expected string
+	}{
+		{
+			name: "changed line",
+			src: "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n\" +\n" +
+				"\t\"  name =    \\\"b\\\"\\n\" + // the name\n" +
+				"\t`}\n`\n",
+			rewrite: func(s string) string { return strings.ReplaceAll(s, "=    ", "= ") },
+			expected: "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n\" +\n" +
+				"\t\"  name = \\\"b\\\"\\n\" + // the name\n" +
+				"\t`}\n`\n",
+		},
+		{
+			name: "added and removed lines",
+			src: "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n\" +\n" +
+				"\t\"  name = \\\"b\\\"\\n\\n\" +\n" +
+				"\t\"}\\n\"\n",
+			rewrite: func(s string) string {
+				return strings.Replace(strings.Replace(s, "\n\n", "\n", 1), "{\n", "{\n  count = 1\n", 1)
+			},
+			expected: "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  count = 1\\n\" +\n" +
+				"\t\"  name = \\\"b\\\"\\n\" +\n" +
+				"\t\"}\\n\"\n",
+		},
+		{
+			name: "split in a line",
+			src: "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  name = \" +\n" +
+				"\t\"\\\"b\\\"\\n}\\n\"\n",
+			rewrite: func(s string) string { return strings.ReplaceAll(s, `"b"`, `"c"`) },
+			// written back as a single literal, as the lines of the literals can not be told apart
+			expected: "package a\n\nvar config = \"resource \\\"a\\\" \\\"c\\\" {\\n  name = \\\"c\\\"\\n}\\n\"\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := Rewrite([]byte(testcase.src), KindGo, func(b Block) (string, error) {
+				return testcase.rewrite(b.Text), nil
+			})
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if string(actual) != testcase.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", testcase.expected, actual)
+			}
+		})
+	}
+}
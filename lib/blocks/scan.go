@@ -0,0 +1,193 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Kind is the kind of host source terraform blocks are embedded in: go, hcl, or the name of a
+// registered text format (markdown, rst, asciidoc, ...).
+type Kind string
+
+const (
+	KindGo               Kind = "go"
+	KindHCL              Kind = "hcl"
+	KindMarkdown         Kind = "markdown"
+	KindRestructuredText Kind = "rst"
+	KindAsciiDoc         Kind = "asciidoc"
+)
+
+// KindForFile returns the kind of host source for filename by its extension, falling back to
+// DefaultTextFormat.
+func KindForFile(filename string) Kind {
+	switch {
+	case strings.HasSuffix(filename, ".go"):
+		return KindGo
+	case IsHCLFile(filename):
+		return KindHCL
+	}
+
+	return Kind(textFormatNameForFile(filename))
+}
+
+// Block is a terraform block found in a host source.
+//
+// Positions are 1 based and the end position is just after the block. For text formats the block is
+// the lines of content between the opening and closing lines, for go it is the whole string
+// expression including its quotes, and for hcl it is the whole file. Text is the terraform itself:
+// for go the unquoted value of the string expression without the padding around it.
+type Block struct {
+	File     string
+	Kind     Kind
+	Language string // the language the block is labelled with, e.g. hcl or terraform, if any
+	Func     string // go only, the function (or Type.Method) the block is in, if any
+	Number   int    // 1 based, counting blocks whose end could not be found
+
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+	StartOffset int
+	EndOffset   int
+
+	Text string
+	Host HostContext
+}
+
+// HostContext describes how a block is embedded in its host source.
+type HostContext struct {
+	Opening string // the line opening the block, e.g. a markdown fence or rst directive
+	Closing string // the line closing the block, empty when it ends at a dedent or the end of the file
+	// Indented is set when the block content is indented to match the host document (e.g. an rst
+	// directive or a markdown list item), its replacement must be indented the same way
+	Indented bool
+
+	Quote           string // go only, the quote character of the (first) string literal
+	LeadingPadding  string // go only, the whitespace before Text in the literal
+	TrailingPadding string // go only, the whitespace after Text in the literal
+}
+
+// Scan yields the terraform blocks in src, a host source of the given kind. If src cannot be read,
+// e.g. it is not valid go or a block is never closed, the blocks found before that are yielded
+// followed by the error.
+func Scan(src []byte, kind Kind) iter.Seq2[Block, error] {
+	return ScanFile("", src, kind)
+}
+
+// ScanFile is Scan for the source of filename, which is used for the blocks' File and in errors.
+func ScanFile(filename string, src []byte, kind Kind) iter.Seq2[Block, error] {
+	return func(yield func(Block, error) bool) {
+		blocks, err := scan(filename, src, kind)
+		for _, b := range blocks {
+			if !yield(b.Block, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(Block{}, err)
+		}
+	}
+}
+
+// Rewrite returns src, a host source of the given kind, with the text of each terraform block
+// replaced by what rewrite returns for it. Returning a block's Text leaves it unchanged, for go the
+// text is re-quoted with the padding it had, literal by literal for a + chain split at line ends (see
+// Reader.ReplaceCurrentString) and as a single literal for any other chain. An error from rewrite
+// stops the rewrite and is returned.
+func Rewrite(src []byte, kind Kind, rewrite func(Block) (string, error)) ([]byte, error) {
+	blocks, err := scan("", src, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []sourceEdit
+	for _, b := range blocks {
+		text, err := rewrite(b.Block)
+		if err != nil {
+			return nil, fmt.Errorf("block %d @ %d:%d: %w", b.Number, b.StartLine, b.StartColumn, err)
+		}
+		if text == b.Text {
+			continue
+		}
+
+		if kind != KindGo {
+			edits = append(edits, sourceEdit{start: b.StartOffset, end: b.EndOffset, text: text})
+
+			continue
+		}
+
+		value := b.Host.LeadingPadding + strings.TrimSuffix(text, "\n") + b.Host.TrailingPadding
+		valueEdits, err := literalEdits(b.literals, value)
+		if err != nil {
+			valueEdits = []sourceEdit{{start: b.StartOffset, end: b.EndOffset, text: GoStringLiteral(b.Host.Quote, value)}}
+		}
+		edits = append(edits, valueEdits...)
+	}
+
+	return spliceEdits(src, edits), nil
+}
+
+// CurrentBlock describes the block BlockRead has been called for.
+func (br *Reader) CurrentBlock() Block {
+	return Block{
+		File:        br.FileName,
+		Kind:        br.Kind,
+		Language:    br.currentLanguage,
+		Func:        br.currentFunc,
+		Number:      br.BlockCount,
+		StartLine:   br.currentStart.Line,
+		StartColumn: br.currentStart.Column,
+		EndLine:     br.currentEnd.Line,
+		EndColumn:   br.currentEnd.Column,
+		StartOffset: br.currentStart.Offset,
+		EndOffset:   br.currentEnd.Offset,
+		Text:        br.currentText,
+		Host:        br.currentHost,
+	}
+}
+
+// scannedBlock is a block found by scan, with the string literals of a go block
+type scannedBlock struct {
+	Block
+	literals []goLiteral
+}
+
+func scan(filename string, src []byte, kind Kind) ([]scannedBlock, error) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	var blocks []scannedBlock
+	br := &Reader{
+		FileName: filename,
+		Log:      log,
+		ReadOnly: true,
+		Writer:   io.Discard,
+		LineRead: ReaderIgnore,
+		BlockRead: func(br *Reader, _ int, b string, _ bool) error {
+			blocks = append(blocks, scannedBlock{Block: br.CurrentBlock(), literals: br.currentLiterals})
+
+			return nil
+		},
+	}
+	br.Kind = kind
+
+	var err error
+	switch kind {
+	case KindGo:
+		err = br.readGo(filename, src)
+	case KindHCL:
+		err = br.readHCL(src)
+	default:
+		var textFmt TextFormat
+		if textFmt, err = NewTextFormat(string(kind)); err == nil {
+			err = br.readText(bytes.NewReader(src), textFmt)
+		}
+	}
+
+	return blocks, err
+}
@@ -0,0 +1,196 @@
+package blocks
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		kind     Kind
+		src      string
+		expected []Block
+	}{
+		{
+			name: "markdown",
+			kind: KindMarkdown,
+			src:  "# Example\n\n```terraform\nresource \"a\" \"b\" {}\n```\n\n~~~~ hcl title=\"main.tf\"\nlocals {}\n~~~~\n",
+			expected: []Block{
+				{
+					Kind: KindMarkdown, Language: "terraform", Number: 1,
+					StartLine: 4, StartColumn: 1, EndLine: 5, EndColumn: 1, StartOffset: 24, EndOffset: 44,
+					Text: "resource \"a\" \"b\" {}\n",
+					Host: HostContext{Opening: "```terraform", Closing: "```"},
+				},
+				{
+					Kind: KindMarkdown, Language: "hcl", Number: 2,
+					StartLine: 8, StartColumn: 1, EndLine: 9, EndColumn: 1, StartOffset: 74, EndOffset: 84,
+					Text: "locals {}\n",
+					Host: HostContext{Opening: "~~~~ hcl title=\"main.tf\"", Closing: "~~~~"},
+				},
+			},
+		},
+		{
+			name: "rst",
+			kind: KindRestructuredText,
+			src:  "Example\n\n.. code-block:: HCL\n   :caption: main.tf\n\n   locals {}\n\nText\n",
+			expected: []Block{
+				{
+					Kind: KindRestructuredText, Language: "hcl", Number: 1,
+					StartLine: 6, StartColumn: 1, EndLine: 8, EndColumn: 1, StartOffset: 51, EndOffset: 65,
+					Text: "   locals {}\n\n",
+					Host: HostContext{Opening: ".. code-block:: HCL", Indented: true},
+				},
+			},
+		},
+		{
+			name: "go",
+			kind: KindGo,
+			src:  "package a\n\nconst config = `\nresource \"a\" \"b\" {\n}\n`\n",
+			expected: []Block{
+				{
+					Kind: KindGo, Number: 1,
+					StartLine: 3, StartColumn: 16, EndLine: 6, EndColumn: 2, StartOffset: 26, EndOffset: 50,
+					Text: "resource \"a\" \"b\" {\n}\n",
+					Host: HostContext{Quote: "`", LeadingPadding: "\n", TrailingPadding: "\n"},
+				},
+			},
+		},
+		{
+			name: "hcl",
+			kind: KindHCL,
+			src:  "locals {}\n\nresource \"a\" \"b\" {}",
+			expected: []Block{
+				{
+					Kind: KindHCL, Language: "hcl", Number: 1,
+					StartLine: 1, StartColumn: 1, EndLine: 3, EndColumn: 20, StartOffset: 0, EndOffset: 30,
+					Text: "locals {}\n\nresource \"a\" \"b\" {}",
+				},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var actual []Block
+			for b, err := range Scan([]byte(testcase.src), testcase.kind) {
+				if err != nil {
+					t.Fatalf("Got an error when none was expected: %v", err)
+				}
+				if text := testcase.src[b.StartOffset:b.EndOffset]; b.Kind != KindGo && text != b.Text {
+					t.Errorf("Block %d offsets select %q, not its text %q", b.Number, text, b.Text)
+				}
+				actual = append(actual, b)
+			}
+
+			if !reflect.DeepEqual(actual, testcase.expected) {
+				t.Errorf("Expected blocks\n%+v\ngot\n%+v", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScanUnterminatedBlock(t *testing.T) {
+	t.Parallel()
+
+	src := "```hcl\nlocals {}\n```\n\n```hcl\nlocals {}\n"
+
+	var found []string
+	var scanErr error
+	for b, err := range ScanFile("README.md", []byte(src), KindForFile("README.md")) {
+		if err != nil {
+			scanErr = err
+			continue
+		}
+		if b.File != "README.md" {
+			t.Errorf("Expected block in README.md, got %q", b.File)
+		}
+		found = append(found, b.Text)
+	}
+
+	if !reflect.DeepEqual(found, []string{"locals {}\n"}) {
+		t.Errorf("Expected the first block to be found, got %q", found)
+	}
+	if scanErr == nil || !strings.Contains(scanErr.Error(), "README.md:5 failed to find end of block") {
+		t.Errorf("Expected an error for the unterminated block, got %v", scanErr)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	rename := func(b Block) (string, error) {
+		return strings.ReplaceAll(b.Text, `"b"`, `"c"`), nil
+	}
+
+	testcases := []struct {
+		name     string
+		kind     Kind
+		src      string
+		expected string
+	}{
+		{
+			name:     "markdown",
+			kind:     KindMarkdown,
+			src:      "# Example\n\n```hcl\nresource \"a\" \"b\" {}\n```\n\n```hcl\nlocals {}\n```\n",
+			expected: "# Example\n\n```hcl\nresource \"a\" \"c\" {}\n```\n\n```hcl\nlocals {}\n```\n",
+		},
+		{
+			name:     "go raw",
+			kind:     KindGo,
+			src:      "package a\n\n// config is an example\nconst config = `\nresource \"a\" \"b\" {\n}\n`\n",
+			expected: "package a\n\n// config is an example\nconst config = `\nresource \"a\" \"c\" {\n}\n`\n",
+		},
+		{
+			name:     "go interpreted",
+			kind:     KindGo,
+			src:      "package a\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n}\\n\"\n",
+			expected: "package a\n\nvar config = \"resource \\\"a\\\" \\\"c\\\" {\\n}\\n\"\n",
+		},
+		{
+			name:     "hcl",
+			kind:     KindHCL,
+			src:      "resource \"a\" \"b\" {}\n",
+			expected: "resource \"a\" \"c\" {}\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := Rewrite([]byte(testcase.src), testcase.kind, rename)
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if string(actual) != testcase.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRewriteError(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad block")
+	src := "```hcl\nlocals {}\n```\n\n```hcl\nresource {\n```\n"
+
+	_, err := Rewrite([]byte(src), KindMarkdown, func(b Block) (string, error) {
+		if b.Number == 2 {
+			return "", errBad
+		}
+
+		return b.Text, nil
+	})
+	if !errors.Is(err, errBad) || !strings.Contains(err.Error(), "block 2 @ 6:1") {
+		t.Errorf("Expected the error of block 2, got %v", err)
+	}
+}
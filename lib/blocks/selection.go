@@ -0,0 +1,29 @@
+package blocks
+
+// Selection picks the blocks of a file to process by their number, a line they are on, or the go
+// function they are in. Every criteria that is set must match, so the zero value selects every block.
+type Selection struct {
+	Number int    // the block with this number
+	Line   int    // the block on this line, including the lines opening and closing it
+	Func   string // the blocks in this go function, Type.Method for a method
+}
+
+// IsZero reports whether the selection selects every block.
+func (s Selection) IsZero() bool {
+	return s == Selection{}
+}
+
+// matches reports whether the block the reader is on is selected
+func (s Selection) matches(br *Reader) bool {
+	if s.Number != 0 && s.Number != br.BlockCount {
+		return false
+	}
+	if s.Line != 0 && (s.Line < br.LineCount-br.BlockCurrentLine || s.Line > br.LineCount) {
+		return false
+	}
+	if s.Func != "" && s.Func != br.currentFunc {
+		return false
+	}
+
+	return true
+}
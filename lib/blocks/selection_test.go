@@ -0,0 +1,129 @@
+package blocks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+func TestSelection(t *testing.T) {
+	t.Parallel()
+
+	goSrc := "package a\n\n" +
+		"func testOne() string {\n\treturn `\nlocals {\n  one = 1\n}\n`\n}\n\n" +
+		"func testTwo() string {\n\treturn `\nlocals {\n  two = 2\n}\n`\n}\n\n" +
+		"func (r Resource) testThree() string {\n\treturn `\nlocals {\n  three = 3\n}\n`\n}\n"
+	mdSrc := "# Example\n\n```hcl\nlocals {}\n```\n\n```hcl\nlocals {\n  two = 2\n}\n```\n"
+
+	testcases := []struct {
+		name      string
+		filename  string
+		src       string
+		selection Selection
+		expected  []int
+	}{
+		{
+			name:     "go all",
+			filename: "a.go",
+			src:      goSrc,
+			expected: []int{1, 2, 3},
+		},
+		{
+			name:      "go number",
+			filename:  "a.go",
+			src:       goSrc,
+			selection: Selection{Number: 2},
+			expected:  []int{2},
+		},
+		{
+			name:      "go line",
+			filename:  "a.go",
+			src:       goSrc,
+			selection: Selection{Line: 15},
+			expected:  []int{2},
+		},
+		{
+			name:      "go func",
+			filename:  "a.go",
+			src:       goSrc,
+			selection: Selection{Func: "testOne"},
+			expected:  []int{1},
+		},
+		{
+			name:      "go method",
+			filename:  "a.go",
+			src:       goSrc,
+			selection: Selection{Func: "Resource.testThree"},
+			expected:  []int{3},
+		},
+		{
+			name:      "go func and number",
+			filename:  "a.go",
+			src:       goSrc,
+			selection: Selection{Func: "testOne", Number: 2},
+		},
+		{
+			name:      "markdown opening line",
+			filename:  "a.md",
+			src:       mdSrc,
+			selection: Selection{Line: 7},
+			expected:  []int{2},
+		},
+		{
+			name:      "markdown closing line",
+			filename:  "a.md",
+			src:       mdSrc,
+			selection: Selection{Line: 5},
+			expected:  []int{1},
+		},
+		{
+			name:      "markdown between blocks",
+			filename:  "a.md",
+			src:       mdSrc,
+			selection: Selection{Line: 6},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := afero.NewMemMapFs()
+			if err := afero.WriteFile(fs, testcase.filename, []byte(testcase.src), 0o644); err != nil {
+				t.Fatalf("Error writing test file: %s", err)
+			}
+
+			var selected []int
+			var funcs []string
+			br := Reader{
+				Log:      logrus.New(),
+				ReadOnly: true,
+				Select:   testcase.selection,
+				LineRead: ReaderIgnore,
+				BlockRead: func(br *Reader, _ int, _ string, _ bool) error {
+					selected = append(selected, br.BlockCount)
+					funcs = append(funcs, br.CurrentBlock().Func)
+
+					return nil
+				},
+			}
+			if err := br.DoTheThing(fs, testcase.filename, nil, &strings.Builder{}); err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			if !reflect.DeepEqual(selected, testcase.expected) {
+				t.Errorf("Expected blocks %v, got %v", testcase.expected, selected)
+			}
+			if testcase.selection.Func != "" {
+				for _, f := range funcs {
+					if f != testcase.selection.Func {
+						t.Errorf("Expected the blocks of %s, got one in %q", testcase.selection.Func, f)
+					}
+				}
+			}
+		})
+	}
+}
@@ -91,6 +91,22 @@ resource "azurerm_storage_container" "UpperCase" {
 `)
 }
 
+func testProviderPreamble() string {
+	return `
+provider "azurerm" {
+  features {}
+}
+`
+}
+
+func testTerraformPreamble() string {
+	return `
+terraform {
+  required_version = ">= 1.0"
+}
+`
+}
+
 func notTerraformSimpleString() string {
 	fmt.Sprintf("%d: bad create: \n%#v\n%#v", i, cm, tc.Create)
 }
@@ -1,54 +1,371 @@
 package blocks
 
 import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"unicode"
 )
 
-type textFormat interface {
-	isStartingLine(line string) bool
-	isFinishLine(line string) bool
-	preserveIndentation() bool
+// TextFormat finds the terraform blocks embedded in a host document (markdown, rst, ...) one line
+// at a time. A new TextFormat is created for every document, so implementations are free to keep
+// state between lines, e.g. a header line that has to come before the block's opening delimiter.
+type TextFormat interface {
+	// IsStartingLine reports whether line opens a block, the next line is the first of its content
+	IsStartingLine(line string) bool
+	// IsFinishLine reports whether line closes the current block
+	IsFinishLine(line string) bool
+	// PreserveIndentation reports whether the block content is indented relative to the document
+	// and the formatted block must be indented to match
+	PreserveIndentation() bool
 }
 
-// used for markdown text
-type markdownTextFormat struct{}
+// HeaderTextFormat is implemented by text formats whose blocks can open with header lines that are
+// part of the host document rather than the block, such as rst directive options. Header lines are
+// only looked for before the first line of block content.
+type HeaderTextFormat interface {
+	TextFormat
+	IsHeaderLine(line string) bool
+}
+
+// OpenEndedTextFormat is implemented by text formats whose blocks are not closed by a delimiter but
+// end at the first line that is no longer part of them, such as an rst directive ending at a dedent.
+// That finish line may open the next block, and a block may also run to the end of the document.
+type OpenEndedTextFormat interface {
+	TextFormat
+	IsOpenEnded() bool
+}
+
+// LanguageTextFormat is implemented by text formats whose blocks are labelled with a language, such
+// as the info string of a markdown fence. Language is only asked for once a block has started.
+type LanguageTextFormat interface {
+	TextFormat
+	Language() string
+}
+
+// TextFormatFactory creates a TextFormat for a single document.
+type TextFormatFactory func() TextFormat
+
+// DefaultTextFormat is used for files whose extension has no registered format, and for stdin.
+const DefaultTextFormat = "markdown"
+
+var textFormats = struct {
+	sync.RWMutex
+	byName      map[string]TextFormatFactory
+	byExtension map[string]string
+}{
+	byName:      map[string]TextFormatFactory{},
+	byExtension: map[string]string{},
+}
+
+func init() {
+	RegisterTextFormat("markdown", func() TextFormat { return &markdownTextFormat{} }, ".md", ".markdown")
+	RegisterTextFormat("rst", func() TextFormat { return &restructuredTextFormat{} }, ".rst")
+	RegisterTextFormat("asciidoc", func() TextFormat { return &asciiDocTextFormat{} }, ".adoc", ".asciidoc")
+}
+
+// RegisterTextFormat makes a text format available by name and for files with any of the given
+// extensions (including the leading dot). Registering an existing name or extension replaces it.
+func RegisterTextFormat(name string, factory TextFormatFactory, extensions ...string) {
+	textFormats.Lock()
+	defer textFormats.Unlock()
+
+	textFormats.byName[name] = factory
+	for _, ext := range extensions {
+		textFormats.byExtension[strings.ToLower(ext)] = name
+	}
+}
+
+// NewTextFormat creates the text format registered under name.
+func NewTextFormat(name string) (TextFormat, error) {
+	textFormats.RLock()
+	defer textFormats.RUnlock()
 
-func (markdownTextFormat) isStartingLine(line string) bool {
-	// fences may be indented, e.g. inside a list item (issue #51)
+	factory, ok := textFormats.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown text format %q", name)
+	}
+
+	return factory(), nil
+}
+
+// TextFormatForFile creates the text format registered for filename's extension, falling back to
+// DefaultTextFormat.
+func TextFormatForFile(filename string) TextFormat {
+	name := textFormatNameForFile(filename)
+
+	textFormats.RLock()
+	factory := textFormats.byName[name]
+	textFormats.RUnlock()
+
+	return factory()
+}
+
+func textFormatNameForFile(filename string) string {
+	textFormats.RLock()
+	defer textFormats.RUnlock()
+
+	if name, ok := textFormats.byExtension[strings.ToLower(filepath.Ext(filename))]; ok {
+		return name
+	}
+
+	return DefaultTextFormat
+}
+
+// TextFormatNames returns the names of all registered text formats, sorted.
+func TextFormatNames() []string {
+	textFormats.RLock()
+	defer textFormats.RUnlock()
+
+	names := make([]string, 0, len(textFormats.byName))
+	for name := range textFormats.byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// used for markdown text, following the CommonMark rules for fenced code blocks: a fence is a run of
+// at least three backticks or tildes followed by an info string whose first word is the language, and
+// it is only closed by a run of the same character that is at least as long with nothing after it
+type markdownTextFormat struct {
+	block    markdownFence // the fence that opened the current terraform block
+	other    markdownFence // the fence of a non-terraform code block being skipped over
+	language string        // the language of the current terraform block
+}
+
+type markdownFence struct {
+	char   rune
+	length int
+}
+
+var markdownLanguages = map[string]bool{
+	"hcl":       true,
+	"terraform": true,
+	"tf":        true,
+	"tfvars":    true,
+}
+
+// parseMarkdownFence returns the fence and info string of a line that is a fence, fences may be
+// indented, e.g. inside a list item (issue #51)
+func parseMarkdownFence(line string) (fence markdownFence, info string, ok bool) {
 	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return markdownFence{}, "", false
+	}
 
-	//nolint:gocritic
-	if strings.HasPrefix(trimmed, "```hcl") { // documentation
-		return true
-	} else if strings.HasPrefix(trimmed, "```terraform") { // documentation
-		return true
-	} else if strings.HasPrefix(trimmed, "```tf") { // documentation
-		return true
+	fence.char = rune(trimmed[0])
+	for fence.length < len(trimmed) && rune(trimmed[fence.length]) == fence.char {
+		fence.length++
+	}
+	if fence.length < 3 {
+		return markdownFence{}, "", false
 	}
 
-	return false
+	info = strings.TrimSpace(trimmed[fence.length:])
+	// the info string of a backtick fence cannot contain backticks, or it would be inline code
+	if fence.char == '`' && strings.ContainsRune(info, '`') {
+		return markdownFence{}, "", false
+	}
+
+	return fence, info, true
+}
+
+// markdownInfoLanguage returns the language of an info string, e.g. hcl for `hcl title="main.tf"`
+// or `hcl{1,3}`
+func markdownInfoLanguage(info string) string {
+	if i := strings.IndexFunc(info, func(r rune) bool { return unicode.IsSpace(r) || r == '{' || r == ',' }); i >= 0 {
+		info = info[:i]
+	}
+
+	return strings.ToLower(info)
 }
 
-func (mbf markdownTextFormat) isFinishLine(line string) bool {
-	return strings.HasPrefix(strings.TrimLeft(line, " \t"), "```")
+// closes reports whether line is a closing fence for f
+func (f markdownFence) closes(line string) bool {
+	fence, info, ok := parseMarkdownFence(line)
+
+	return ok && info == "" && fence.char == f.char && fence.length >= f.length
 }
 
-func (mbf markdownTextFormat) preserveIndentation() bool {
+func (f *markdownTextFormat) IsStartingLine(line string) bool {
+	// nothing starts inside a fenced block, including another fence
+	if f.block.length > 0 {
+		return false
+	}
+
+	if f.other.length > 0 {
+		if f.other.closes(line) {
+			f.other = markdownFence{}
+		}
+
+		return false
+	}
+
+	fence, info, ok := parseMarkdownFence(line)
+	if !ok {
+		return false
+	}
+
+	language := markdownInfoLanguage(info)
+	if !markdownLanguages[language] {
+		f.other = fence
+		return false
+	}
+
+	f.block = fence
+	f.language = language
+
+	return true
+}
+
+func (f *markdownTextFormat) IsFinishLine(line string) bool {
+	if !f.block.closes(line) {
+		return false
+	}
+	f.block = markdownFence{}
+
+	return true
+}
+
+func (*markdownTextFormat) PreserveIndentation() bool {
 	return false
 }
 
-// used for restructured text
-type restructuredTextFormat struct{}
+func (f *markdownTextFormat) Language() string {
+	return f.language
+}
+
+var (
+	restructuredTextDirectiveMatcher = regexp.MustCompile(`^([ \t]*)\.\.[ \t]+(?:code|code-block|sourcecode)::[ \t]+((?i:terraform|hcl|tf))\s*$`)
+	restructuredTextOptionMatcher    = regexp.MustCompile(`^[ \t]*:[^:\s][^:]*:(\s|$)`)
+)
+
+// used for restructured text, a code directive (code, code-block, or sourcecode) with a terraform or
+// hcl language. Its content is everything indented further than the directive, so directives nested
+// in lists or admonitions work too:
+//
+//	.. code-block:: hcl
+//	   :caption: main.tf
+//
+//	   resource "a" "b" {}
+type restructuredTextFormat struct {
+	inBlock  bool
+	indent   int    // indentation of the directive that opened the current block
+	language string // the language of the directive that opened the current block
+}
+
+func restructuredTextIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func restructuredTextBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func (f *restructuredTextFormat) IsStartingLine(line string) bool {
+	if f.inBlock {
+		return false
+	}
+
+	m := restructuredTextDirectiveMatcher.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	f.inBlock = true
+	f.indent = len(m[1])
+	f.language = strings.ToLower(m[2])
+
+	return true
+}
+
+// IsHeaderLine skips the directive options (:caption: main.tf, :linenos:, ...) and the blank line
+// separating them from the content
+func (f *restructuredTextFormat) IsHeaderLine(line string) bool {
+	if restructuredTextBlank(line) {
+		return true
+	}
+
+	return restructuredTextIndent(line) > f.indent && restructuredTextOptionMatcher.MatchString(line)
+}
+
+func (f *restructuredTextFormat) IsFinishLine(line string) bool {
+	if restructuredTextBlank(line) || restructuredTextIndent(line) > f.indent {
+		return false
+	}
+	f.inBlock = false
+
+	return true
+}
 
-func (restructuredTextFormat) isStartingLine(line string) bool {
-	return strings.HasPrefix(line, ".. code:: terraform")
+func (*restructuredTextFormat) IsOpenEnded() bool {
+	return true
 }
 
-func (mbf restructuredTextFormat) isFinishLine(line string) bool {
-	return line == strings.TrimLeftFunc(line, unicode.IsSpace)
+func (*restructuredTextFormat) PreserveIndentation() bool {
+	return true
 }
 
-func (mbf restructuredTextFormat) preserveIndentation() bool {
+func (f *restructuredTextFormat) Language() string {
+	return f.language
+}
+
+var (
+	asciiDocSourceMatcher    = regexp.MustCompile(`^\[source,\s*(terraform|hcl|tf)\s*(,[^\]]*)?\]\s*$`)
+	asciiDocDelimiterMatcher = regexp.MustCompile(`^-{4,}\s*$`)
+)
+
+// used for asciidoc, a listing block with a terraform or hcl source style:
+//
+//	[source,terraform]
+//	----
+//	resource "a" "b" {}
+//	----
+type asciiDocTextFormat struct {
+	sourceStyle bool   // the previous line(s) were a terraform source style, and optionally a block title
+	delimiter   string // the delimiter that opened the current block
+	language    string // the language of the last source style
+}
+
+func (f *asciiDocTextFormat) IsStartingLine(line string) bool {
+	trimmed := strings.TrimRightFunc(line, unicode.IsSpace)
+
+	if m := asciiDocSourceMatcher.FindStringSubmatch(trimmed); m != nil {
+		f.sourceStyle = true
+		f.language = m[1]
+		return false
+	}
+
+	if !f.sourceStyle {
+		return false
+	}
+
+	// a block title (.main.tf) may sit between the source style and the delimiter
+	if strings.HasPrefix(trimmed, ".") && !strings.HasPrefix(trimmed, "..") {
+		return false
+	}
+
+	f.sourceStyle = false
+	if !asciiDocDelimiterMatcher.MatchString(trimmed) {
+		return false
+	}
+	f.delimiter = trimmed
+
 	return true
 }
+
+func (f *asciiDocTextFormat) IsFinishLine(line string) bool {
+	return f.delimiter != "" && strings.TrimRightFunc(line, unicode.IsSpace) == f.delimiter
+}
+
+func (*asciiDocTextFormat) PreserveIndentation() bool {
+	return false
+}
+
+func (f *asciiDocTextFormat) Language() string {
+	return f.language
+}
@@ -0,0 +1,51 @@
+package blocks
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testTextFormat struct{}
+
+func (*testTextFormat) IsStartingLine(line string) bool { return line == "<<<\n" }
+func (*testTextFormat) IsFinishLine(line string) bool   { return line == ">>>\n" }
+func (*testTextFormat) PreserveIndentation() bool       { return false }
+
+//nolint:paralleltest // registers a text format in the package level registry
+func TestTextFormatRegistry(t *testing.T) {
+	RegisterTextFormat("test", func() TextFormat { return &testTextFormat{} }, ".TEST")
+
+	testcases := []struct {
+		filename string
+		expected TextFormat
+	}{
+		{filename: "", expected: &markdownTextFormat{}},
+		{filename: "README.md", expected: &markdownTextFormat{}},
+		{filename: "doc.markdown", expected: &markdownTextFormat{}},
+		{filename: "unknown.txt", expected: &markdownTextFormat{}},
+		{filename: "docs/index.rst", expected: &restructuredTextFormat{}},
+		{filename: "docs/index.adoc", expected: &asciiDocTextFormat{}},
+		{filename: "docs/index.asciidoc", expected: &asciiDocTextFormat{}},
+		{filename: "custom.test", expected: &testTextFormat{}},
+	}
+
+	for _, testcase := range testcases {
+		actual := TextFormatForFile(testcase.filename)
+		if reflect.TypeOf(actual) != reflect.TypeOf(testcase.expected) {
+			t.Errorf("Case %q: expected %T, got %T", testcase.filename, testcase.expected, actual)
+		}
+	}
+
+	if _, err := NewTextFormat("test"); err != nil {
+		t.Errorf("Expected registered text format, got: %v", err)
+	}
+
+	if _, err := NewTextFormat("does-not-exist"); err == nil {
+		t.Errorf("Expected an error for an unregistered text format")
+	}
+
+	expectedNames := []string{"asciidoc", "markdown", "rst", "test"}
+	if names := TextFormatNames(); !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected names %v, got %v", expectedNames, names)
+	}
+}
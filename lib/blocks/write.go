@@ -0,0 +1,143 @@
+package blocks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// fileState is what a file looked like when it was read
+type fileState struct {
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// newFileState snapshots info, some afero file systems return a FileInfo that tracks later changes
+func newFileState(info os.FileInfo) fileState {
+	return fileState{
+		mode:    info.Mode(),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+}
+
+// writeFile replaces the content of filename with everything read from content. original is the
+// state of filename when it was read: if its size or modification time has changed since then (e.g.
+// an editor saved it) the file is not replaced and an error is returned instead.
+//
+// The content is written to a temporary file next to the file filename is, or links to, that is
+// synced and then renamed over it, so a crash or full disk part way through can never leave the file
+// truncated. The temporary file is given the permissions of the original, and the directory is synced
+// after the rename so the rename itself survives a crash. A symlink is followed rather than replaced.
+// A file with other hard links is refused with an error: renaming over it would split it from its
+// links, and writing it in place could leave it truncated.
+func writeFile(fs afero.Fs, filename string, original fileState, content io.Reader) (err error) {
+	target, err := resolveSymlinks(fs, filename)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := afero.TempFile(fs, filepath.Dir(target), "."+filepath.Base(target)+".terrafmt-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// whatever goes wrong, don't leave the temporary file behind
+	defer func() {
+		if err != nil {
+			_ = fs.Remove(tmpName)
+		}
+	}()
+
+	_, err = io.Copy(tmp, content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	// a failed close on a just-written file can mean lost data, so it takes over err (unless
+	// the write already failed, in which case that error wins)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = fs.Chmod(tmpName, original.mode.Perm()); err != nil {
+		return err
+	}
+
+	current, err := fs.Stat(target)
+	if err != nil {
+		return err
+	}
+	if current.Size() != original.size || !current.ModTime().Equal(original.modTime) {
+		return fmt.Errorf("%s was modified while it was being formatted, not replacing it", filename)
+	}
+
+	if n := hardLinks(current); n > 1 {
+		return fmt.Errorf("%s has %d hard links, which replacing it would break, not replacing it", filename, n)
+	}
+
+	if err = fs.Rename(tmpName, target); err != nil {
+		return err
+	}
+
+	syncDir(fs, filepath.Dir(target))
+
+	return nil
+}
+
+// maxSymlinks is how many symlinks resolveSymlinks follows before giving up on a loop
+const maxSymlinks = 255
+
+// resolveSymlinks returns the file filename links to, following any chain of symlinks. File systems
+// without symlinks return filename as it is.
+func resolveSymlinks(fs afero.Fs, filename string) (string, error) {
+	lstater, ok := fs.(afero.Lstater)
+	if !ok {
+		return filename, nil
+	}
+	reader, ok := fs.(afero.LinkReader)
+	if !ok {
+		return filename, nil
+	}
+
+	for range maxSymlinks {
+		info, lstated, err := lstater.LstatIfPossible(filename)
+		if err != nil {
+			return "", err
+		}
+		if !lstated || info.Mode()&os.ModeSymlink == 0 {
+			return filename, nil
+		}
+
+		link, err := reader.ReadlinkIfPossible(filename)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(filename), link)
+		}
+		filename = link
+	}
+
+	return "", fmt.Errorf("%s: too many levels of symbolic links", filename)
+}
+
+// syncDir flushes dir so a rename in it is on disk. This is best effort: not every platform can
+// sync a directory, and the file itself is already complete.
+func syncDir(fs afero.Fs, dir string) {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,178 @@
+package blocks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "docs/main.md", []byte("original\n"), 0o600); err != nil {
+		t.Fatalf("Error writing test file: %s", err)
+	}
+	info, err := fs.Stat("docs/main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file info: %s", err)
+	}
+
+	if err := writeFile(fs, "docs/main.md", newFileState(info), strings.NewReader("formatted\n")); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	data, err := afero.ReadFile(fs, "docs/main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file: %s", err)
+	}
+	if string(data) != "formatted\n" {
+		t.Errorf("Expected the new content, got %q", string(data))
+	}
+
+	newInfo, err := fs.Stat("docs/main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file info: %s", err)
+	}
+	if newInfo.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the permissions to be kept as %v, got %v", os.FileMode(0o600), newInfo.Mode().Perm())
+	}
+
+	assertNoTempFiles(t, fs, "docs")
+}
+
+func TestWriteFileModifiedSinceRead(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "main.md", []byte("original\n"), 0o644); err != nil {
+		t.Fatalf("Error writing test file: %s", err)
+	}
+	info, err := fs.Stat("main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file info: %s", err)
+	}
+	original := newFileState(info)
+
+	// an editor saves the file while it is being formatted
+	if err := afero.WriteFile(fs, "main.md", []byte("saved by an editor\n"), 0o644); err != nil {
+		t.Fatalf("Error writing test file: %s", err)
+	}
+	if err := fs.Chtimes("main.md", time.Now(), original.modTime.Add(time.Second)); err != nil {
+		t.Fatalf("Error changing test file times: %s", err)
+	}
+
+	err = writeFile(fs, "main.md", original, strings.NewReader("formatted\n"))
+	if err == nil || !strings.Contains(err.Error(), "was modified while it was being formatted") {
+		t.Errorf("Expected a modified file error, got: %v", err)
+	}
+
+	data, err := afero.ReadFile(fs, "main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file: %s", err)
+	}
+	if string(data) != "saved by an editor\n" {
+		t.Errorf("Expected the editor's content to be kept, got %q", string(data))
+	}
+
+	assertNoTempFiles(t, fs, ".")
+}
+
+func assertNoTempFiles(t *testing.T, fs afero.Fs, dir string) {
+	t.Helper()
+
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		t.Fatalf("Error reading %q: %s", dir, err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".terrafmt-") {
+			t.Errorf("Expected no temporary files to be left behind, found %q", entry.Name())
+		}
+	}
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+	if err := fs.Mkdir("docs", 0o755); err != nil {
+		t.Fatalf("Error creating test directory: %s", err)
+	}
+	if err := afero.WriteFile(fs, "docs/main.md", []byte("original\n"), 0o644); err != nil {
+		t.Fatalf("Error writing test file: %s", err)
+	}
+	if err := os.Symlink(filepath.Join("docs", "main.md"), filepath.Join(dir, "README.md")); err != nil {
+		t.Skipf("Symlinks are not supported: %s", err)
+	}
+	info, err := fs.Stat("README.md")
+	if err != nil {
+		t.Fatalf("Error reading test file info: %s", err)
+	}
+
+	if err := writeFile(fs, "README.md", newFileState(info), strings.NewReader("formatted\n")); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	linkInfo, err := os.Lstat(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatalf("Error reading link info: %s", err)
+	}
+	if linkInfo.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected README.md to still be a symlink, got mode %v", linkInfo.Mode())
+	}
+
+	data, err := afero.ReadFile(fs, "docs/main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file: %s", err)
+	}
+	if string(data) != "formatted\n" {
+		t.Errorf("Expected the link target to have the new content, got %q", string(data))
+	}
+
+	assertNoTempFiles(t, fs, ".")
+	assertNoTempFiles(t, fs, "docs")
+}
+
+func TestWriteFileHardLink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+	if err := afero.WriteFile(fs, "main.md", []byte("original\n"), 0o644); err != nil {
+		t.Fatalf("Error writing test file: %s", err)
+	}
+	if err := os.Link(filepath.Join(dir, "main.md"), filepath.Join(dir, "copy.md")); err != nil {
+		t.Skipf("Hard links are not supported: %s", err)
+	}
+	info, err := fs.Stat("main.md")
+	if err != nil {
+		t.Fatalf("Error reading test file info: %s", err)
+	}
+
+	if hardLinks(info) < 2 {
+		t.Skip("Hard links are not counted on this platform")
+	}
+
+	err = writeFile(fs, "main.md", newFileState(info), strings.NewReader("formatted\n"))
+	if err == nil || !strings.Contains(err.Error(), "hard links") {
+		t.Errorf("Expected the hard linked file to be refused, got %v", err)
+	}
+
+	for _, filename := range []string{"main.md", "copy.md"} {
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			t.Fatalf("Error reading test file: %s", err)
+		}
+		if string(data) != "original\n" {
+			t.Errorf("Expected %s to be left as it was, got %q", filename, string(data))
+		}
+	}
+
+	assertNoTempFiles(t, fs, ".")
+}
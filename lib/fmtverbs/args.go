@@ -0,0 +1,128 @@
+package fmtverbs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Argument is an argument of the format a verb uses, numbered from 1 as the fmt package does.
+type Argument struct {
+	Verb    Verb
+	Number  int  // 0 for an index of 0, which is never an argument
+	Indexed bool // the argument is picked with an explicit index, e.g. %[2]s
+	Star    bool // the argument is the width or precision of the verb (*) rather than its value
+}
+
+// Arguments returns the arguments each of verbs uses, in order, the way the fmt package works them
+// out: each verb and each * uses the argument after the last one used, unless an explicit index
+// ([n]) before it picks the argument, after which they carry on from there.
+func Arguments(verbs []Verb) []Argument {
+	var args []Argument
+	next := 1
+	for _, v := range verbs {
+		text := v.Text
+		i := 1
+		for i < len(text) && strings.IndexByte("+-# 0", text[i]) >= 0 {
+			i++
+		}
+
+		indexed := false
+		use := func(star bool) {
+			args = append(args, Argument{Verb: v, Number: next, Indexed: indexed, Star: star})
+			next++
+			indexed = false
+		}
+		index := func() {
+			if n := argIndexLen(text[i:]); n > 0 {
+				next, _ = strconv.Atoi(text[i+1 : i+n-1])
+				indexed = true
+				i += n
+			}
+		}
+
+		index()
+		if n := widthLen(text[i:]); n > 0 {
+			if text[i] == '*' {
+				use(true)
+			}
+			i += n
+		}
+		if i < len(text) && text[i] == '.' {
+			i++
+			index()
+			if n := widthLen(text[i:]); n > 0 {
+				if text[i] == '*' {
+					use(true)
+				}
+				i += n
+			}
+		}
+		index()
+		use(false)
+	}
+
+	return args
+}
+
+// Positional returns format with every verb picking its arguments with explicit indexes, e.g. %s %d
+// becomes %[1]s %[2]d and %*d becomes %[1]*[2]d, so the verbs can be reordered and repeated. number
+// maps each argument a verb uses in format (see Arguments) to the one it uses in the result, so
+// arguments can be merged or reordered too.
+func Positional(format string, number func(arg int) int) string {
+	verbs := Verbs(format)
+	args := Arguments(verbs)
+
+	var b strings.Builder
+	pos := 0
+	for _, v := range verbs {
+		var verbArgs []Argument
+		for len(args) > 0 && args[0].Verb.Start == v.Start {
+			verbArgs = append(verbArgs, args[0])
+			args = args[1:]
+		}
+		index := func() string {
+			a := verbArgs[0]
+			verbArgs = verbArgs[1:]
+
+			return "[" + strconv.Itoa(number(a.Number)) + "]"
+		}
+
+		text := v.Text
+		i := 1
+		for i < len(text) && strings.IndexByte("+-# 0", text[i]) >= 0 {
+			i++
+		}
+
+		b.WriteString(format[pos:v.Start])
+		b.WriteString(text[:i])
+
+		i += argIndexLen(text[i:])
+		if n := widthLen(text[i:]); n > 0 {
+			if text[i] == '*' {
+				b.WriteString(index())
+			}
+			b.WriteString(text[i : i+n])
+			i += n
+		}
+		if i < len(text) && text[i] == '.' {
+			b.WriteByte('.')
+			i++
+			i += argIndexLen(text[i:])
+			if n := widthLen(text[i:]); n > 0 {
+				if text[i] == '*' {
+					b.WriteString(index())
+				}
+				b.WriteString(text[i : i+n])
+				i += n
+			}
+		}
+		i += argIndexLen(text[i:])
+		b.WriteString(index())
+		b.WriteString(text[i:])
+
+		pos = v.End
+	}
+	b.WriteString(format[pos:])
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package fmtverbs
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestArguments(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		format   string
+		expected []string // verb:argument, with * for a width or precision and [] for an index
+	}{
+		{
+			format:   "a = %s\nb = %d\n",
+			expected: []string{"%s:1", "%d:2"},
+		},
+		{
+			format:   "a = %[2]s\nb = %[1]d\nc = %s\n",
+			expected: []string{"%[2]s:[2]", "%[1]d:[1]", "%s:2"},
+		},
+		{
+			format:   "a = \"%*d-%.*f\"\n",
+			expected: []string{"%*d:1*", "%*d:2", "%.*f:3*", "%.*f:4"},
+		},
+		{
+			format:   "a = \"%[3]*.[2]*[1]f\"\n",
+			expected: []string{"%[3]*.[2]*[1]f:[3]*", "%[3]*.[2]*[1]f:[2]*", "%[3]*.[2]*[1]f:[1]"},
+		},
+		{
+			format:   "# %s\na = \"%%s\"\nb = %[0]s\n",
+			expected: []string{"%s:1", "%[0]s:[0]"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.format, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, a := range Arguments(Verbs(testcase.format)) {
+				n := fmt.Sprint(a.Number)
+				if a.Indexed {
+					n = "[" + n + "]"
+				}
+				if a.Star {
+					n += "*"
+				}
+				got = append(got, a.Verb.Text+":"+n)
+			}
+
+			if !slices.Equal(got, testcase.expected) {
+				t.Errorf("Expected %v, got %v", testcase.expected, got)
+			}
+		})
+	}
+}
+
+func TestPositional(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		format   string
+		number   []int // the argument each argument of the format becomes
+		expected string
+	}{
+		{
+			name:     "sequential",
+			format:   "a = %s\nb = \"%-10d\"\n",
+			number:   []int{1, 2},
+			expected: "a = %[1]s\nb = \"%-10[2]d\"\n",
+		},
+		{
+			name:     "merged",
+			format:   "a = \"%s-%d-%s\"\n",
+			number:   []int{1, 2, 1},
+			expected: "a = \"%[1]s-%[2]d-%[1]s\"\n",
+		},
+		{
+			name:     "mixed",
+			format:   "a = %[2]s\nb = %s\nc = %[1]q\n",
+			number:   []int{1, 2, 3},
+			expected: "a = %[2]s\nb = %[3]s\nc = %[1]q\n",
+		},
+		{
+			name:     "width and precision",
+			format:   "a = \"%*d %6.*f %%\"\n",
+			number:   []int{1, 2, 3, 4},
+			expected: "a = \"%[1]*[2]d %6.[3]*[4]f %%\"\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := Positional(testcase.format, func(arg int) int { return testcase.number[arg-1] })
+			if actual != testcase.expected {
+				t.Errorf("Expected %q, got %q", testcase.expected, actual)
+			}
+		})
+	}
+}
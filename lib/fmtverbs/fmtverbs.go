@@ -1,144 +1,183 @@
 // Package fmtverbs escapes Go format verbs (%s, %d, %[n]s, ...) in terraform blocks so they
 // survive a round trip through the HCL formatter, and unescapes them afterwards.
+//
+// Each verb is found by scanning the block (see Verbs) and swapped for a unique placeholder that is
+// valid HCL where the verb is: an identifier in expressions, a quoted label for block labels, and a
+// comment for lines of their own. Verbs in strings, heredocs, and comments are already valid HCL and
+// are left as they are. The placeholders are recorded in order, so unescaping puts back exactly the
+// verbs that were escaped and fails rather than guessing when the formatter lost or repeated one.
 package fmtverbs
 
 import (
-	"regexp"
+	"errors"
+	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
-func Escape(b string) string {
-	// NOTE: the order of these replacements matter
+// markers are the runes placeholders start with, the first that is not in a block is used so a
+// placeholder can never be confused with the block's own text. They are letters, so placeholders
+// are valid identifiers.
+var markers = []rune{'Ω', 'Ψ', 'Φ', 'Ξ', 'Σ', 'Δ', 'Λ', 'Π'}
 
-	// conditional expression: = %t ? ...
-	b = regexp.MustCompile(`(=\s*)(%\[[\d+]\]t)(\s\?)`).ReplaceAllString(b, `${1}true/*@@_@@ TFMT:${2}:TFMT @@_@@*/${3}`)
+const placeholderDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
-	// resource name contains %[sdtfg]
-	b = regexp.MustCompile(`((?:resource|data|list|ephemeral|action)\s+"[-a-zA-Z0-9_]+"\s+"[-a-zA-Z0-9_]*)%(?:\[(\d+)\])?([sdtfg])`).ReplaceAllString(b, `${1}TFMTRESNAME_${2}${3}`)
+// ErrNoMarker is returned by Escape for a block that contains every rune placeholders can start with.
+var ErrNoMarker = errors.New("the block contains every placeholder marker, its format verbs can not be escaped")
 
-	// resource name %q
-	b = regexp.MustCompile(`((?:resource|data|list|ephemeral|action)\s+"[-a-zA-Z0-9_]+"\s+)%(?:\[(\d+)\])?q`).ReplaceAllString(b, `${1}"TFMTRESNAME_${2}q"`)
+// Escaped is a block with its format verbs swapped for placeholders, and the table of placeholders
+// to swap them back.
+type Escaped struct {
+	Text string // the escaped block
 
-	// %s - whole line
-	// figure out why the * doesn't match both later
-	b = regexp.MustCompile(`(?m:^%(\.\d)?[sdfgtq]$)`).ReplaceAllString(b, `#@@_@@ TFMT:$0:TMFT @@_@@#`)
-	b = regexp.MustCompile(`(?m:^[ \t]*%(\.\d)?[sdfgtq]$)`).ReplaceAllString(b, `#@@_@@ TFMT:$0:TMFT @@_@@#`)
-
-	// provider meta-argument
-	// The provider name must be in lowercase
-	b = regexp.MustCompile(`(\bprovider\s+=\s+)%s`).ReplaceAllString(b, `${1}tfmtprovider.PROVIDER`)
-	b = regexp.MustCompile(`(\bprovider\s+=\s+)+%\[(\d+)\]s`).ReplaceAllString(b, `${1}tfmtprovider.PROVIDER_${2}_`)
-
-	// count meta-argument
-	b = regexp.MustCompile(`(\bcount\s+=\s+)%([ds])`).ReplaceAllString(b, `${1}1 # tfmtcount_${2}`)
-	b = regexp.MustCompile(`(\bcount\s+=\s+)+%(\[(\d+)\][ds])`).ReplaceAllString(b, `${1}1 # tfmtcount_${2}`)
-
-	// %[n]s
-	b = regexp.MustCompile(`(?m:^%(\.\d)?\[\d+\][sdfgtq]$)`).ReplaceAllString(b, `#@@_@@ TFMT:$0:TMFT @@_@@#`)
-	b = regexp.MustCompile(`(?m:^[ \t]*%(\.\d)?\[\d+\][sdfgtq]$)`).ReplaceAllString(b, `#@@_@@ TFMT:$0:TMFT @@_@@#`)
-
-	// %s =
-	b = regexp.MustCompile(`(?m)^([ \t]*)%((\.\d)?[sdfgtq])`).ReplaceAllString(b, `${1}Ω${2}`)
-
-	// %[n]s =
-	b = regexp.MustCompile(`(?m)^([ \t]*)%(\.\d)?\[(\d+)\]([sdfgtq])`).ReplaceAllString(b, `${1}Ω${2}_${3}_${4}`)
-
-	// = "${...[%([n])d]}"
-	b = regexp.MustCompile(`(?m)("\${.*\[)(%(?:\.\d)?(?:\[\d+\])?d)(\]}")$`).ReplaceAllString(b, `${1}0/*@@_@@ TFMT:$2:TFMT @@_@@*/$3`)
-
-	// = [%s(, %s)]
-	b = regexp.MustCompile(`(?m:\[(%(\.\d)?[sdfgtq](,\s*)?)+\])`).ReplaceAllString(b, `["@@_@@ TFMT:$0:TFMT @@_@@"]`)
-
-	// = [%[n]s(, %[n]s)]
-	b = regexp.MustCompile(`(?m:\[(%(\.\d)?\[\d+\][sdfgtq](,\s*)?)+\])`).ReplaceAllString(b, `["@@_@@ TFMT:$0:TFMT @@_@@"]`)
-
-	//  .12 - something.%s.prop
-	b = regexp.MustCompile(`\.%([sdt])`).ReplaceAllString(b, `.TFMTKTKTTFMT${1}`)
-
-	//  .12 - something.%[n]s.prop
-	b = regexp.MustCompile(`\.%\[(\d+)\]([sdt])`).ReplaceAllString(b, `.TFMTKTKTTFMT_${1}${2}`)
-
-	//  .12 - something.text%s.prop
-	b = regexp.MustCompile(`\.([-a-zA-Z0-9_]+)%([sdt])`).ReplaceAllString(b, `.${1}TFMTKTKTTFMT${2}`)
-
-	//  .12 - something.text%[n]s.prop
-	b = regexp.MustCompile(`\.([-a-zA-Z0-9_]+)%\[(\d+)\]([sdt])`).ReplaceAllString(b, `.${1}TFMTKTKTTFMT_${2}${3}`)
-
-	// = %s (an optional trailing comma, e.g. a tags map entry, is kept outside the marker)
-	b = regexp.MustCompile(`(?m)(%(\.\d)?[sdfgtq](\.[a-z_]+)*)(,?)$`).ReplaceAllString(b, `"@@_@@ TFMT:${1}:TFMT @@_@@"${4}`)
-
-	// = %[n]s (an optional trailing comma, e.g. a tags map entry, is kept outside the marker)
-	b = regexp.MustCompile(`(?m)(%(\.\d)?\[\d+\][sdfgtq](\.[a-z_]+)*)(,?)$`).ReplaceAllString(b, `"@@_@@ TFMT:${1}:TFMT @@_@@"${4}`)
-
-	// function(..., %s, ...)
-	// function(..., %[n]s, ...)
-	b = regexp.MustCompile(`\(.*%(\[(\d+)\])?[sdfgtq].*?\)`).ReplaceAllStringFunc(b, func(v string) string {
-		result := regexp.MustCompile(`%([sdfgtq])`).ReplaceAllString(v, `TFMTFNPARAM_$1`)
-		result = regexp.MustCompile(`%\[(\d+)\]([sdfgtq])`).ReplaceAllString(result, `TFMTFNPARAM_$1$2`)
-		return result
-	})
-
-	return b
+	marker       rune
+	placeholders []placeholder
 }
 
-func Unscape(fb string) string {
-	// NOTE: the order of these replacements matter
-
-	// undo replace
-	fb = regexp.MustCompile(` *#@@_@@ TFMT:`).ReplaceAllString(fb, ``)
-	fb = strings.ReplaceAll(fb, "#@@_@@ TFMT:", "")
-	fb = strings.ReplaceAll(fb, ":TMFT @@_@@#", "")
-
-	// the list form wraps a $0 that itself starts with [ and ends with ], so anchor on those
-	// brackets to avoid eating a literal [ that precedes a plain marker (e.g. a block containing `[%s`)
-	fb = strings.ReplaceAll(fb, "[\"@@_@@ TFMT:[", "[")
-	fb = strings.ReplaceAll(fb, "]:TFMT @@_@@\"]", "]")
-
-	fb = strings.ReplaceAll(fb, "0/*@@_@@ TFMT:", "")
-	fb = regexp.MustCompile(`true\s*/\*@@_@@ TFMT:`).ReplaceAllString(fb, ``)
-	fb = strings.ReplaceAll(fb, ":TFMT @@_@@*/", "")
-
-	// order here matters, replace the ones with [], then do the ones without
-	fb = strings.ReplaceAll(fb, "\"@@_@@ TFMT:", "")
-	fb = strings.ReplaceAll(fb, ":TFMT @@_@@\"", "")
-
-	// .12 - something.%[n]s.prop
-	fb = regexp.MustCompile(`TFMTKTKTTFMT_(\d+)`).ReplaceAllString(fb, `%[$1]`)
-
-	// .12 - something.%s.prop
-	fb = strings.ReplaceAll(fb, "TFMTKTKTTFMT", "%")
-
-	// function(..., %[n]s, ...)
-	fb = regexp.MustCompile(`TFMTFNPARAM_(\d+)([sdfgtq])`).ReplaceAllString(fb, `%[$1]$2`)
-
-	// function(..., %s, ...)
-	fb = strings.ReplaceAll(fb, "TFMTFNPARAM_", "%")
-
-	// %[n]s =
-	fb = regexp.MustCompile(`Ω(\.\d)?_(\d+)_([sdfgtq])`).ReplaceAllString(fb, `%${1}[${2}]${3}`)
+type placeholder struct {
+	verb         Verb
+	text         string // the placeholder in Escaped.Text
+	markerOffset int    // the byte offset of the marker in text
+	original     string // what text replaced, the verb, or for a line the whole line up to the verb
+}
 
-	// %s =
-	fb = regexp.MustCompile(`Ω(\.\d)?([sdfgtq])`).ReplaceAllString(fb, `%${1}${2}`)
+// PlaceholderError is returned by Unescape when the placeholder of a verb is missing from the
+// formatted block, or is in it more than once.
+type PlaceholderError struct {
+	Verb       Verb
+	Duplicated bool
+}
 
-	// resource name %[n]s
-	fb = regexp.MustCompile(`TFMTRESNAME_(\d+)([sdtfg])`).ReplaceAllString(fb, `%[${1}]${2}`)
+func (e *PlaceholderError) Error() string {
+	what := "is missing"
+	if e.Duplicated {
+		what = "is duplicated"
+	}
 
-	// resource name %s
-	fb = regexp.MustCompile(`TFMTRESNAME_([sdtfg])`).ReplaceAllString(fb, `%${1}`)
+	return fmt.Sprintf("the placeholder of format verb %s @ %d:%d %s after formatting", e.Verb.Text, e.Verb.Line, e.Verb.Column, what)
+}
 
-	// resource name %[n]q
-	fb = regexp.MustCompile(`"TFMTRESNAME_(\d+)q"`).ReplaceAllString(fb, `%[${1}]q`)
+// Escape swaps the format verbs of block that are not valid HCL for placeholders.
+func Escape(block string) (*Escaped, error) {
+	e := &Escaped{}
+	for _, m := range markers {
+		if !strings.ContainsRune(block, m) {
+			e.marker = m
+
+			break
+		}
+	}
+	if e.marker == 0 {
+		return nil, ErrNoMarker
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, v := range Verbs(block) {
+		var p placeholder
+		id := placeholderID(len(e.placeholders))
+
+		switch v.Context {
+		case ContextExpression:
+			// the same width as the verb, so attributes are aligned the same once it is put back
+			if pad := utf8.RuneCountInString(v.Text) - 1 - len(id); pad > 0 {
+				id = strings.Repeat("0", pad) + id
+			}
+			p = placeholder{text: string(e.marker) + id, original: v.Text}
+		case ContextLabel:
+			p = placeholder{text: `"` + string(e.marker) + id + `"`, markerOffset: 1, original: v.Text}
+		case ContextLine:
+			lineStart := strings.LastIndexByte(block[:v.Start], '\n') + 1
+			p = placeholder{text: "#" + string(e.marker) + id, markerOffset: 1, original: block[lineStart:v.End]}
+			v.Start = lineStart
+		default:
+			continue
+		}
+		p.verb = v
+
+		b.WriteString(block[pos:v.Start])
+		b.WriteString(p.text)
+		pos = v.End
+		e.placeholders = append(e.placeholders, p)
+	}
+	b.WriteString(block[pos:])
+	e.Text = b.String()
+
+	return e, nil
+}
 
-	// resource name %q
-	fb = regexp.MustCompile(`"TFMTRESNAME_q"`).ReplaceAllLiteralString(fb, `%q`)
+// placeholderID returns the digits identifying the nth placeholder
+func placeholderID(n int) string {
+	base := len(placeholderDigits)
+	id := string(placeholderDigits[n%base])
+	for n /= base; n > 0; n /= base {
+		id = string(placeholderDigits[n%base]) + id
+	}
 
-	// count meta-argument
-	fb = regexp.MustCompile(`1\s+# tfmtcount_(\[\d+\][ds])`).ReplaceAllString(fb, `%${1}`)
-	fb = regexp.MustCompile(`1\s+# tfmtcount_([ds])`).ReplaceAllString(fb, `%${1}`)
+	return id
+}
 
-	// provider meta-argument
-	fb = regexp.MustCompile(`tfmtprovider.PROVIDER_(\d+)_`).ReplaceAllString(fb, `%[${1}]s`)
-	fb = strings.ReplaceAll(fb, "tfmtprovider.PROVIDER", "%s")
+// Unescape swaps the placeholders in formatted, the escaped block after formatting, back for the
+// verbs they replaced. Placeholders are expected in the order they were made, a *PlaceholderError is
+// returned for one that is missing or duplicated.
+func (e *Escaped) Unescape(formatted string) (string, error) {
+	var b strings.Builder
+	pos := 0
+	for i, p := range e.placeholders {
+		m := strings.IndexRune(formatted[pos:], e.marker)
+		if m < 0 {
+			return "", &PlaceholderError{Verb: p.verb}
+		}
+		m += pos
+
+		start := m - p.markerOffset
+		if start < pos || !strings.HasPrefix(formatted[start:], p.text) {
+			if k := e.placeholderAt(formatted, m); k >= 0 && k < i {
+				return "", &PlaceholderError{Verb: e.placeholders[k].verb, Duplicated: true}
+			}
+
+			return "", &PlaceholderError{Verb: p.verb}
+		}
+
+		if p.verb.Context == ContextLine {
+			// the formatter indents the comment, the line is put back as it was
+			lineStart := strings.LastIndexByte(formatted[:start], '\n') + 1
+			if lineStart < pos || strings.Trim(formatted[lineStart:start], " \t") != "" {
+				return "", &PlaceholderError{Verb: p.verb}
+			}
+			start = lineStart
+		}
+
+		b.WriteString(formatted[pos:start])
+		b.WriteString(p.original)
+		pos = m - p.markerOffset + len(p.text)
+	}
+
+	if m := strings.IndexRune(formatted[pos:], e.marker); m >= 0 {
+		if k := e.placeholderAt(formatted, pos+m); k >= 0 {
+			return "", &PlaceholderError{Verb: e.placeholders[k].verb, Duplicated: true}
+		}
+
+		return "", fmt.Errorf("an unknown placeholder is in the formatted block at byte %d", pos+m)
+	}
+	b.WriteString(formatted[pos:])
+
+	return b.String(), nil
+}
 
-	return fb
+// placeholderAt returns the index of the placeholder whose marker is at offset m of formatted, or -1.
+// A placeholder can be the start of a longer one (Ω1 and Ω10), so the longest that matches is used.
+func (e *Escaped) placeholderAt(formatted string, m int) int {
+	found := -1
+	for k, p := range e.placeholders {
+		start := m - p.markerOffset
+		if start < 0 || !strings.HasPrefix(formatted[start:], p.text) {
+			continue
+		}
+		if found < 0 || len(p.text)-p.markerOffset > len(e.placeholders[found].text)-e.placeholders[found].markerOffset {
+			found = k
+		}
+	}
+
+	return found
 }
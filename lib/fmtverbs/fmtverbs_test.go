@@ -1,8 +1,12 @@
 package fmtverbs
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/kylelemons/godebug/diff"
 )
 
@@ -48,24 +52,24 @@ resource  "resource"   "test" {
     %g
 `,
 			expected: `
-#@@_@@ TFMT:%s:TMFT @@_@@#
-#@@_@@ TFMT:    %s:TMFT @@_@@#
-#@@_@@ TFMT:	%s:TMFT @@_@@#
+#Ω0
+#Ω1
+#Ω2
 
-#@@_@@ TFMT:%d:TMFT @@_@@#
-#@@_@@ TFMT:    %d:TMFT @@_@@#
+#Ω3
+#Ω4
 
-#@@_@@ TFMT:%t:TMFT @@_@@#
-#@@_@@ TFMT:    %t:TMFT @@_@@#
+#Ω5
+#Ω6
 
-#@@_@@ TFMT:%q:TMFT @@_@@#
-#@@_@@ TFMT:    %q:TMFT @@_@@#
+#Ω7
+#Ω8
 
-#@@_@@ TFMT:%f:TMFT @@_@@#
-#@@_@@ TFMT:    %f:TMFT @@_@@#
+#Ω9
+#Ωa
 
-#@@_@@ TFMT:%g:TMFT @@_@@#
-#@@_@@ TFMT:    %g:TMFT @@_@@#
+#Ωb
+#Ωc
 `,
 		},
 
@@ -92,24 +96,24 @@ resource  "resource"   "test" {
     %[2]g
 `,
 			expected: `
-#@@_@@ TFMT:%[1]s:TMFT @@_@@#
-#@@_@@ TFMT:    %[7]s:TMFT @@_@@#
-#@@_@@ TFMT:	%[77]s:TMFT @@_@@#
+#Ω0
+#Ω1
+#Ω2
 
-#@@_@@ TFMT:%[7]d:TMFT @@_@@#
-#@@_@@ TFMT:    %[7]d:TMFT @@_@@#
+#Ω3
+#Ω4
 
-#@@_@@ TFMT:%[42]t:TMFT @@_@@#
-#@@_@@ TFMT:    %[1]t:TMFT @@_@@#
+#Ω5
+#Ω6
 
-#@@_@@ TFMT:%[7]q:TMFT @@_@@#
-#@@_@@ TFMT:    %[77]q:TMFT @@_@@#
+#Ω7
+#Ω8
 
-#@@_@@ TFMT:%[7]f:TMFT @@_@@#
-#@@_@@ TFMT:    %[77]f:TMFT @@_@@#
+#Ω9
+#Ωa
 
-#@@_@@ TFMT:%[1]g:TMFT @@_@@#
-#@@_@@ TFMT:    %[2]g:TMFT @@_@@#
+#Ωb
+#Ωc
 `,
 		},
 
@@ -125,9 +129,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = ["@@_@@ TFMT:[%s]:TFMT @@_@@"]
-  mega = ["@@_@@ TFMT:[%d]:TFMT @@_@@"]
-  byte = ["@@_@@ TFMT:[%d]:TFMT @@_@@"]
+  kat  = [Ω0]
+  mega = [Ω1]
+  byte = [Ω2]
   size = ["%s"]
 }
 `,
@@ -144,9 +148,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = ["@@_@@ TFMT:[%[1]s]:TFMT @@_@@"]
-  mega = ["@@_@@ TFMT:[%[1]d]:TFMT @@_@@"]
-  byte = ["@@_@@ TFMT:[%[1]d]:TFMT @@_@@"]
+  kat  = [Ω0000]
+  mega = [Ω0001]
+  byte = [Ω0002]
   size = ["%[1]s"]
 }
 `,
@@ -164,9 +168,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = "@@_@@ TFMT:%s:TFMT @@_@@"
-  mega = "@@_@@ TFMT:%d:TFMT @@_@@"
-  byte = "@@_@@ TFMT:%d:TFMT @@_@@"
+  kat  = Ω0
+  mega = Ω1
+  byte = Ω2
   size = "%s"
 }
 `,
@@ -181,8 +185,8 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = "@@_@@ TFMT:%s.id:TFMT @@_@@"
-  byte = "@@_@@ TFMT:%d.id:TFMT @@_@@"
+  kat  = Ω0.id
+  byte = Ω1.id
 }
 `,
 		},
@@ -198,9 +202,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = "@@_@@ TFMT:%[1]s:TFMT @@_@@"
-  mega = "@@_@@ TFMT:%[22]d:TFMT @@_@@"
-  byte = "@@_@@ TFMT:%[333]d:TFMT @@_@@"
+  kat  = Ω0000
+  mega = Ω00001
+  byte = Ω000002
   size = "%[42]s"
 }
 `,
@@ -215,8 +219,8 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = "@@_@@ TFMT:%[1]s.id:TFMT @@_@@"
-  byte = "@@_@@ TFMT:%[1]d.id:TFMT @@_@@"
+  kat  = Ω0000.id
+  byte = Ω0001.id
 }
 `,
 		},
@@ -234,12 +238,12 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = base64encode(TFMTFNPARAM_s)
-  byte = md5(data.source.TFMTKTKTTFMTs.id)
-  kat  = base64encode(TFMTFNPARAM_1s)
-  byte = md5(data.source.TFMTKTKTTFMT_1s.id)
-  name = replace(TFMTFNPARAM_1q, "-", "_")
-  mega = split(TFMTFNPARAM_q, "a-b")
+  kat  = base64encode(Ω0)
+  byte = md5(data.source.Ω1.id)
+  kat  = base64encode(Ω0002)
+  byte = md5(data.source.Ω0003.id)
+  name = replace(Ω0004, "-", "_")
+  mega = split(Ω5, "a-b")
 }
 `,
 		},
@@ -254,9 +258,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  s = data.source.TFMTKTKTTFMTs.id
-  d = data.source.TFMTKTKTTFMTd.id
-  t = data.source.TFMTKTKTTFMTt.id
+  s = data.source.Ω0.id
+  d = data.source.Ω1.id
+  t = data.source.Ω2.id
 }
 `,
 		},
@@ -271,9 +275,9 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  s = data.source.TFMTKTKTTFMT_1s.id
-  d = data.source.TFMTKTKTTFMT_2d.id
-  t = data.source.TFMTKTKTTFMT_5t.id
+  s = data.source.Ω0000.id
+  d = data.source.Ω0001.id
+  t = data.source.Ω0002.id
 }
 `,
 		},
@@ -300,20 +304,20 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = ["@@_@@ TFMT:[%s, %s]:TFMT @@_@@"]
-  mega = ["@@_@@ TFMT:[%d, %d]:TFMT @@_@@"]
-  byte = ["@@_@@ TFMT:[%d, %d]:TFMT @@_@@"]
+  kat  = [Ω0, Ω1]
+  mega = [Ω2, Ω3]
+  byte = [Ω4, Ω5]
   size = ["%s", "%s"]
 
   tags = {
-    Ωq = "@@_@@ TFMT:%q:TFMT @@_@@"
+    Ω6 = Ω7
   }
 }
 
 resource "resource" "test" {
-  kat  = ["@@_@@ TFMT:[%s, %s,%s]:TFMT @@_@@"]
-  mega = ["@@_@@ TFMT:[%d, %d,%d]:TFMT @@_@@"]
-  byte = ["@@_@@ TFMT:[%d, %d,%d]:TFMT @@_@@"]
+  kat  = [Ω8, Ω9,Ωa]
+  mega = [Ωb, Ωc,Ωd]
+  byte = [Ωe, Ωf,Ωg]
   size = ["%s", "%s","%s"]
 }
 `,
@@ -334,13 +338,13 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = ["@@_@@ TFMT:[%[1]s, %[2]s,%[3]s]:TFMT @@_@@"]
-  mega = ["@@_@@ TFMT:[%[1]d, %[3]d,%[2]d]:TFMT @@_@@"]
-  byte = ["@@_@@ TFMT:[%[1]d, %[3]d,%[2]d]:TFMT @@_@@"]
+  kat  = [Ω0000, Ω0001,Ω0002]
+  mega = [Ω0003, Ω0004,Ω0005]
+  byte = [Ω0006, Ω0007,Ω0008]
   size = ["%[1]s", "%[2]s","%[3]s"]
 
   tags = {
-    Ω_1_q = "@@_@@ TFMT:%[2]q:TFMT @@_@@"
+    Ω0009 = Ω000a
   }
 }
 `,
@@ -361,11 +365,11 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  name = "@@_@@ TFMT:%[1]q:TFMT @@_@@"
+  name = Ω0000
 
   tags = {
-    Ω_2_q = "@@_@@ TFMT:%[3]q:TFMT @@_@@",
-    Ωq = "@@_@@ TFMT:%q:TFMT @@_@@",
+    Ω0001 = Ω0002,
+    Ω3 = Ω4,
   }
 }
 `,
@@ -401,13 +405,13 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  kat  = "${%s.name}"
-  byte = "${%[5]s.name}"
+  kat  = "${Ω0.name}"
+  byte = "${Ω0001.name}"
 }
 
 resource "resource" "test" {
-  kat  = "${azurerm_key_vault_certificate.test.*.id[0/*@@_@@ TFMT:%d:TFMT @@_@@*/]}"
-  byte = "${azurerm_key_vault_certificate.test.*.id[0/*@@_@@ TFMT:%[2]d:TFMT @@_@@*/]}"
+  kat  = "${azurerm_key_vault_certificate.test.*.id[Ω2]}"
+  byte = "${azurerm_key_vault_certificate.test.*.id[Ω0003]}"
 }
 `,
 		},
@@ -425,11 +429,11 @@ resource "azurerm_cosmosdb_table" "test" {
 `,
 			expected: `
 resource "azurerm_cosmosdb_table" "test" {
-  name = "@@_@@ TFMT:%[1]q:TFMT @@_@@"
+  name = Ω0000
 
   ttl {
-    attribute_name = true/*@@_@@ TFMT:%[2]t:TFMT @@_@@*/ ? "TestTTL" : ""
-    enabled        = "@@_@@ TFMT:%[2]t:TFMT @@_@@"
+    attribute_name = Ω0001 ? "TestTTL" : ""
+    enabled        = Ω0002
   }
 }
 `,
@@ -446,10 +450,10 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  attr = azurerm_key_vault_certificate.test["@@_@@ TFMT:[%[2]d]:TFMT @@_@@"].id
-  attr = "${azurerm_key_vault_certificate.test.*.id[0/*@@_@@ TFMT:%[2]d:TFMT @@_@@*/]}"
-  attr = azurerm_key_vault_certificate.test["@@_@@ TFMT:[%d]:TFMT @@_@@"].id
-  attr = "${azurerm_key_vault_certificate.test.*.id[0/*@@_@@ TFMT:%d:TFMT @@_@@*/]}"
+  attr = azurerm_key_vault_certificate.test[Ω0000].id
+  attr = "${azurerm_key_vault_certificate.test.*.id[Ω0001]}"
+  attr = azurerm_key_vault_certificate.test[Ω2].id
+  attr = "${azurerm_key_vault_certificate.test.*.id[Ω3]}"
 }
 `,
 		},
@@ -486,30 +490,30 @@ resource "resource" "test6" {
 `,
 			expected: `
 resource "resource" "test1" {
-  Ωs = "@@_@@ TFMT:%q:TFMT @@_@@"
+  Ω0 = Ω1
 }
 
 resource "resource" "test2" {
-  Ω_1_s = "@@_@@ TFMT:%q:TFMT @@_@@"
+  Ω0002 = Ω3
 }
 
 resource "resource" "test3" {
-  Ωs = "@@_@@ TFMT:%[3]q:TFMT @@_@@"
+  Ω4 = Ω0005
 }
 
 resource "resource" "test4" {
-  Ω_6_s = "@@_@@ TFMT:%[2]q:TFMT @@_@@"
+  Ω0006 = Ω0007
 }
 
 resource "resource" "test5" {
-  Ωs = {
-#@@_@@ TFMT:    %[3]q:TMFT @@_@@#
+  Ω8 = {
+#Ω9
   }
 }
 
 resource "resource" "test6" {
-  Ω_4_s = {
-#@@_@@ TFMT:    %[2]q:TMFT @@_@@#
+  Ω000a = {
+#Ωb
   }
 }
 `,
@@ -528,12 +532,12 @@ resource "resource" "test" {
 `,
 			expected: `
 resource "resource" "test" {
-  attr = [for x in range(1, TFMTFNPARAM_d+1) : element(azurerm_subnet.test[*].address_prefixes, x)]
-  attr = [for x in range(1, TFMTFNPARAM_2d+1) : element(azurerm_subnet.test[*].address_prefixes, x)]
-  attr = [for x in range(TFMTFNPARAM_d, 3) : element(azurerm_subnet.test[*].address_prefixes, x)]
-  attr = [for x in range(TFMTFNPARAM_1d, 3) : element(azurerm_subnet.test[*].address_prefixes, x)]
-  attr = [for x in range(TFMTFNPARAM_d, TFMTFNPARAM_d) : element(azurerm_subnet.test[*].address_prefixes, x)]
-  attr = [for x in range(TFMTFNPARAM_1d, TFMTFNPARAM_2d) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(1, Ω0+1) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(1, Ω0001+1) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(Ω2, 3) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(Ω0003, 3) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(Ω4, Ω5) : element(azurerm_subnet.test[*].address_prefixes, x)]
+  attr = [for x in range(Ω0006, Ω0007) : element(azurerm_subnet.test[*].address_prefixes, x)]
 }
 `,
 		},
@@ -560,23 +564,23 @@ resource "resource" %q {
 }
 `,
 			expected: `
-resource "resource" "TFMTRESNAME_s" {
-  kat = resource.test-TFMTKTKTTFMTs.byte
+resource "resource" "%s" {
+  kat = resource.test-Ω0.byte
 }
 
-resource "resource" "test-TFMTRESNAME_1s" {
-  kat = resource.TFMTKTKTTFMTs-test.byte
+resource "resource" "test-%[1]s" {
+  kat = resource.Ω1-test.byte
 }
 
-resource "resource" "TFMTRESNAME_s-test" {
-  kat = resource.TFMTKTKTTFMTs.byte
+resource "resource" "%s-test" {
+  kat = resource.Ω2.byte
 }
 
-data "data_source" "TFMTRESNAME_1q" {
+data "data_source" "Ω3" {
 }
 
-resource "resource" "TFMTRESNAME_q" {
-  kat = resource.TFMTKTKTTFMT_1s.byte
+resource "resource" "Ω4" {
+  kat = resource.Ω0005.byte
 }
 `,
 		},
@@ -593,13 +597,13 @@ list "azurerm_thing" %q {
 }
 `,
 			expected: `
-list "azurerm_thing" "TFMTRESNAME_s" {
+list "azurerm_thing" "%s" {
 }
 
-list "azurerm_thing" "test-TFMTRESNAME_1s" {
+list "azurerm_thing" "test-%[1]s" {
 }
 
-list "azurerm_thing" "TFMTRESNAME_q" {
+list "azurerm_thing" "Ω0" {
 }
 `,
 		},
@@ -619,16 +623,16 @@ action "azurerm_virtual_machine_run_command" %q {
 }
 `,
 			expected: `
-ephemeral "azurerm_key_vault_secret" "TFMTRESNAME_s" {
+ephemeral "azurerm_key_vault_secret" "%s" {
 }
 
-ephemeral "azurerm_key_vault_secret" "test-TFMTRESNAME_1s" {
+ephemeral "azurerm_key_vault_secret" "test-%[1]s" {
 }
 
-action "azurerm_virtual_machine_run_command" "TFMTRESNAME_s" {
+action "azurerm_virtual_machine_run_command" "%s" {
 }
 
-action "azurerm_virtual_machine_run_command" "TFMTRESNAME_q" {
+action "azurerm_virtual_machine_run_command" "Ω0" {
 }
 `,
 		},
@@ -645,11 +649,192 @@ resource "resource" "test2" {
 `,
 			expected: `
 resource "resource" "test" {
-  provider = tfmtprovider.PROVIDER
+  provider = Ω0
 }
 
 resource "resource" "test2" {
-  provider = tfmtprovider.PROVIDER_1_
+  provider = Ω0001
+}
+`,
+		},
+		{
+			name: "verb in heredoc",
+			block: `
+resource "azurerm_policy_definition" "test" {
+  policy_rule = <<POLICY
+{
+  "field": "%[2]s",
+  "effect": %q
+}
+POLICY
+
+  metadata = <<-EOT
+    ${%s}
+    %%{ if %t }enabled%%{ endif }
+  EOT
+}
+`,
+			expected: `
+resource "azurerm_policy_definition" "test" {
+  policy_rule = <<POLICY
+{
+  "field": "%[2]s",
+  "effect": %q
+}
+POLICY
+
+  metadata = <<-EOT
+    ${Ω0}
+    %%{ if %t }enabled%%{ endif }
+  EOT
+}
+`,
+		},
+		{
+			name: "verb in template interpolation",
+			block: `
+resource "azurerm_storage_account" "test" {
+  resource_group_name = "${azurerm_resource_group.%s.name}"
+  location            = "${upper(%q)}-${var.suffixes[%d]}"
+  name                = "acct%s"
+}
+`,
+			expected: `
+resource "azurerm_storage_account" "test" {
+  resource_group_name = "${azurerm_resource_group.Ω0.name}"
+  location            = "${upper(Ω1)}-${var.suffixes[Ω2]}"
+  name                = "acct%s"
+}
+`,
+		},
+		{
+			name: "verb as object key",
+			block: `
+resource "azurerm_virtual_network" "test" {
+  tags = { %s = "first" }
+
+  labels = {
+    %[2]q         = %[3]q
+    "environment" = %[2]s
+  }
+}
+`,
+			expected: `
+resource "azurerm_virtual_network" "test" {
+  tags = { Ω0 = "first" }
+
+  labels = {
+    Ω0001         = Ω0002
+    "environment" = Ω0003
+  }
+}
+`,
+		},
+		{
+			name: "verb in for_each",
+			block: `
+resource "azurerm_subnet" "test" {
+  for_each = %s
+}
+
+resource "azurerm_network_security_group" "test" {
+  for_each = { for k, v in %s : k => v if v != %q }
+}
+`,
+			expected: `
+resource "azurerm_subnet" "test" {
+  for_each = Ω0
+}
+
+resource "azurerm_network_security_group" "test" {
+  for_each = { for k, v in Ω1 : k => v if v != Ω2 }
+}
+`,
+		},
+		{
+			name: "verb in depends_on",
+			block: `
+resource "azurerm_linux_virtual_machine" "test" {
+  depends_on = [azurerm_subnet.test, %s, %[1]s]
+}
+
+resource "azurerm_virtual_machine_extension" "test" {
+  depends_on = [
+    azurerm_linux_virtual_machine.test,
+    %[2]s,
+  ]
+}
+`,
+			expected: `
+resource "azurerm_linux_virtual_machine" "test" {
+  depends_on = [azurerm_subnet.test, Ω0, Ω0001]
+}
+
+resource "azurerm_virtual_machine_extension" "test" {
+  depends_on = [
+    azurerm_linux_virtual_machine.test,
+    Ω0002,
+  ]
+}
+`,
+		},
+		{
+			name: "verb in dynamic block label",
+			block: `
+resource "azurerm_kubernetes_cluster" "test" {
+  dynamic "%s" {
+    content {
+      name = %[1]s.value
+    }
+  }
+
+  dynamic %q {
+    for_each = []
+  }
+}
+`,
+			expected: `
+resource "azurerm_kubernetes_cluster" "test" {
+  dynamic "%s" {
+    content {
+      name = Ω0000.value
+    }
+  }
+
+  dynamic "Ω1" {
+    for_each = []
+  }
+}
+`,
+		},
+		{
+			name: "flags width and precision",
+			block: `
+resource "resource" %+q {
+  name    = %-10s
+  size    = %5d
+  value   = %+v
+  hex     = %#x
+  width   = %*d
+  indexed = %[2]*[1]d
+  ratio   = %6.2f
+  text    = format("%%s-%s", %v)
+  percent = "100%%"
+  %-v
+}
+`,
+			expected: `
+resource "resource" "Ω0" {
+  name    = Ω0001
+  size    = Ω02
+  value   = Ω03
+  hex     = Ω04
+  width   = Ω05
+  indexed = Ω00000006
+  ratio   = Ω0007
+  text    = format("%%s-%s", Ω8)
+  percent = "100%%"
+#Ω9
 }
 `,
 		},
@@ -682,27 +867,27 @@ resource "other_resource" "test6" {
 `,
 			expected: `
 resource "resource" "test" {
-  count = 1 # tfmtcount_d
+  count = Ω0
 }
 
 resource "resource" "test2" {
-  count = 1 # tfmtcount_[2]d
+  count = Ω0001
 }
 
 resource "resource" "test3" {
-  count = 1 # tfmtcount_s
+  count = Ω2
 }
 
 resource "resource" "test4" {
-  count = 1 # tfmtcount_[3]s
+  count = Ω0003
 }
 
 resource "other_resource" "test5" {
-  replica_count = "@@_@@ TFMT:%d:TFMT @@_@@"
+  replica_count = Ω4
 }
 
 resource "other_resource" "test6" {
-  replica_count = "@@_@@ TFMT:%[2]d:TFMT @@_@@"
+  replica_count = Ω0005
 }
 `,
 		},
@@ -714,15 +899,211 @@ resource "other_resource" "test6" {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			result := Escape(test.block)
+			e, err := Escape(test.block)
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			result := e.Text
 			if result != test.expected {
 				t.Fatalf("Unexpected escaped result: ('-' actual, '+' expected)\n%s\n", diff.Diff(result, test.expected))
 			}
 
-			roundtrip := Unscape(result)
+			// some blocks set an attribute more than once to show more cases, only the syntax is checked
+			_, diags := hclsyntax.ParseConfig([]byte(result), test.name, hcl.InitialPos)
+			for _, d := range diags {
+				if d.Severity == hcl.DiagError && d.Summary != "Attribute redefined" {
+					t.Fatalf("Escaped block is not valid HCL: %v", d)
+				}
+			}
+
+			roundtrip, err := e.Unescape(result)
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
 			if roundtrip != test.block {
 				t.Fatalf("Did not roundtrip: ('-' actual, '+' expected)\n%s\n", diff.Diff(roundtrip, test.block))
 			}
 		})
 	}
 }
+
+func TestVerbs(t *testing.T) {
+	t.Parallel()
+
+	block := `# a %s comment
+resource "a" %q {
+  name = "${var.%s}-%d"
+  tags = {
+    %s
+  }
+
+  policy = <<EOF
+%[1]s
+EOF
+
+  %[2]s
+  count = %d
+}
+`
+
+	expected := []Verb{
+		{Text: "%s", Line: 1, Column: 5, Context: ContextComment},
+		{Text: "%q", Line: 2, Column: 14, Context: ContextLabel},
+		{Text: "%s", Line: 3, Column: 17, Context: ContextExpression},
+		{Text: "%d", Line: 3, Column: 21, Context: ContextTemplate},
+		{Text: "%s", Line: 5, Column: 5, Context: ContextLine},
+		{Text: "%[1]s", Line: 9, Column: 1, Context: ContextTemplate},
+		{Text: "%[2]s", Line: 12, Column: 3, Context: ContextLine},
+		{Text: "%d", Line: 13, Column: 11, Context: ContextExpression},
+	}
+
+	verbs := Verbs(block)
+	if len(verbs) != len(expected) {
+		t.Fatalf("Expected %d verbs, got %d: %+v", len(expected), len(verbs), verbs)
+	}
+	for i, v := range verbs {
+		if block[v.Start:v.End] != v.Text {
+			t.Errorf("Verb %d: offsets %d:%d are %q, not %q", i, v.Start, v.End, block[v.Start:v.End], v.Text)
+		}
+		v.Start, v.End = 0, 0
+		if v != expected[i] {
+			t.Errorf("Verb %d: expected %+v, got %+v", i, expected[i], v)
+		}
+	}
+}
+
+func TestEscapeMarker(t *testing.T) {
+	t.Parallel()
+
+	e, err := Escape("a = \"Ω\"\nb = %s\n")
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if e.Text != "a = \"Ω\"\nb = Ψ0\n" {
+		t.Errorf("Expected the next marker to be used, got %q", e.Text)
+	}
+
+	if _, err := Escape(string(markers) + " = %s\n"); !errors.Is(err, ErrNoMarker) {
+		t.Errorf("Expected ErrNoMarker, got %v", err)
+	}
+}
+
+func TestUnescapeErrors(t *testing.T) {
+	t.Parallel()
+
+	block := "a = %s\nb = %d\n"
+	e, err := Escape(block)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	testcases := []struct {
+		name       string
+		formatted  string
+		verb       string
+		duplicated bool
+	}{
+		{
+			name:      "missing",
+			formatted: "a = Ω0\n",
+			verb:      "%d",
+		},
+		{
+			name:      "reordered",
+			formatted: "b = Ω1\na = Ω0\n",
+			verb:      "%s",
+		},
+		{
+			name:       "duplicated",
+			formatted:  "a = Ω0\nb = Ω1\nc = Ω1\n",
+			verb:       "%d",
+			duplicated: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := e.Unescape(testcase.formatted)
+
+			var placeholderErr *PlaceholderError
+			if !errors.As(err, &placeholderErr) {
+				t.Fatalf("Expected a *PlaceholderError, got %v", err)
+			}
+			if placeholderErr.Verb.Text != testcase.verb || placeholderErr.Duplicated != testcase.duplicated {
+				t.Errorf("Expected verb %s (duplicated %t), got %s (duplicated %t)", testcase.verb, testcase.duplicated, placeholderErr.Verb.Text, placeholderErr.Duplicated)
+			}
+			if !strings.Contains(err.Error(), testcase.verb) {
+				t.Errorf("Expected the error to name %s, got %q", testcase.verb, err)
+			}
+		})
+	}
+}
+
+func TestVerbLen(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]int{
+		"%s":             2,
+		"%v":             2,
+		"%x":             2,
+		"%T":             2,
+		"%5d":            3,
+		"%-10s":          5,
+		"%+v":            3,
+		"%#v":            3,
+		"% x":            3,
+		"%08.3f":         6,
+		"%.f":            3,
+		"%*d":            3,
+		"%.*f":           4,
+		"%[1]s":          5,
+		"%[2]*[1]d":      9,
+		"%[1]*.[2]*[3]f": 14,
+		"%%":             0,
+		"%%s":            0,
+		"%":              0,
+		"%5":             0,
+		"%z":             0,
+		"%[a]s":          0,
+		"% 2 ":           0,
+		"%s.example":     2,
+	}
+
+	for s, expected := range testcases {
+		if n := verbLen(s); n != expected {
+			t.Errorf("verbLen(%q): expected %d, got %d", s, expected, n)
+		}
+	}
+}
+
+func TestVerbsModulo(t *testing.T) {
+	t.Parallel()
+
+	// a % and space before an operand is the modulo operator in an expression, but a verb in a template
+	block := `locals {
+  a = count.index % var.n
+  b = x % each.value
+  c = %d % 2
+  d = "% d"
+}
+`
+
+	expected := []Verb{
+		{Text: "%d", Line: 4, Column: 7, Context: ContextExpression},
+		{Text: "% d", Line: 5, Column: 8, Context: ContextTemplate},
+	}
+
+	verbs := Verbs(block)
+	if len(verbs) != len(expected) {
+		t.Fatalf("Expected %d verbs, got %d: %+v", len(expected), len(verbs), verbs)
+	}
+	for i, v := range verbs {
+		v.Start, v.End = 0, 0
+		if v != expected[i] {
+			t.Errorf("Verb %d: expected %+v, got %+v", i, expected[i], v)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package fmtverbs
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Context is where in a block a verb is, which decides how it is escaped.
+type Context int
+
+const (
+	// ContextExpression is a verb in an expression, an attribute name or object key, or a step of a
+	// traversal (e.g. data.source.%s.id), it is escaped as an identifier
+	ContextExpression Context = iota
+	// ContextLabel is a verb used as an unquoted block label (resource "a" %q {), it is escaped as a
+	// quoted label
+	ContextLabel
+	// ContextLine is a verb on a line of its own in a block body or object, standing in for any number
+	// of attributes or blocks, it is escaped as a comment line
+	ContextLine
+	// ContextTemplate is a verb in the literal text of a quoted string or heredoc, it is already valid
+	// HCL so it is left as it is
+	ContextTemplate
+	// ContextComment is a verb in a comment, it is left as it is
+	ContextComment
+)
+
+func (c Context) String() string {
+	switch c {
+	case ContextExpression:
+		return "expression"
+	case ContextLabel:
+		return "label"
+	case ContextLine:
+		return "line"
+	case ContextTemplate:
+		return "template"
+	case ContextComment:
+		return "comment"
+	}
+
+	return "unknown"
+}
+
+// Verb is a go format verb found in a block.
+type Verb struct {
+	Text    string
+	Start   int // byte offsets of Text in the block
+	End     int
+	Line    int // 1 based
+	Column  int // 1 based, in characters
+	Context Context
+}
+
+type frameKind int
+
+const (
+	frameBody    frameKind = iota // the body of a block
+	frameObject                   // an object constructor or for expression in braces
+	frameParen                    // a function call or parenthesised expression
+	frameBracket                  // a tuple, index, or splat
+	frameInterp                   // a ${ interpolation or %{ directive in a template
+	frameString                   // a quoted template
+	frameHeredoc                  // a heredoc template
+)
+
+type frame struct {
+	kind       frameKind
+	terminator string // the heredoc's closing marker
+}
+
+// scanner walks a block keeping track of the HCL construct each position is in
+type scanner struct {
+	src   string
+	stack []frame
+	verbs []Verb
+
+	lastSig     byte // the last significant character outside of templates and comments
+	lineEquals  bool // an = has been seen on the current line outside of templates
+	lineStart   int
+	lineNumber  int
+	heredocLine bool // the scanner is at the start of a heredoc line
+}
+
+// Verbs returns the go format verbs in block, in the order they appear, with the context each is in.
+// The block does not need to be valid HCL, the scanner only follows strings, heredocs, comments, and
+// brackets.
+func Verbs(block string) []Verb {
+	s := &scanner{src: block, lineNumber: 1}
+	s.scan()
+
+	return s.verbs
+}
+
+func (s *scanner) top() frameKind {
+	if len(s.stack) == 0 {
+		return frameBody
+	}
+
+	return s.stack[len(s.stack)-1].kind
+}
+
+func (s *scanner) push(f frame) {
+	s.stack = append(s.stack, f)
+}
+
+func (s *scanner) pop() {
+	if len(s.stack) > 0 {
+		s.stack = s.stack[:len(s.stack)-1]
+	}
+}
+
+func (s *scanner) newline(i int) {
+	s.lineStart = i + 1
+	s.lineNumber++
+	s.lineEquals = false
+}
+
+func (s *scanner) scan() {
+	src := s.src
+	for i := 0; i < len(src); {
+		switch s.top() {
+		case frameString:
+			i = s.scanString(i)
+		case frameHeredoc:
+			i = s.scanHeredoc(i)
+		default:
+			i = s.scanExpression(i)
+		}
+	}
+}
+
+// scanExpression scans the character at i outside of any template, returning where to continue
+func (s *scanner) scanExpression(i int) int {
+	src := s.src
+	c := src[i]
+
+	switch {
+	case c == '\n':
+		s.newline(i)
+
+		return i + 1
+	case c == ' ' || c == '\t' || c == '\r':
+		return i + 1
+	case c == '#' || strings.HasPrefix(src[i:], "//"):
+		end := strings.IndexByte(src[i:], '\n')
+		if end < 0 {
+			end = len(src) - i
+		}
+		s.comment(i, i+end)
+
+		return i + end
+	case strings.HasPrefix(src[i:], "/*"):
+		end := strings.Index(src[i+2:], "*/")
+		if end < 0 {
+			end = len(src) - i
+		} else {
+			end += 4
+		}
+		s.comment(i, i+end)
+
+		return i + end
+	case c == '"':
+		s.push(frame{kind: frameString})
+
+		return i + 1
+	case strings.HasPrefix(src[i:], "<<"):
+		if marker, body := heredocStart(src[i:]); body > 0 {
+			s.push(frame{kind: frameHeredoc, terminator: marker})
+			s.newline(i + body - 1)
+			s.heredocLine = true
+
+			return i + body
+		}
+	case c == '{':
+		if s.lastSig == '"' || isIdentByte(s.lastSig) {
+			s.push(frame{kind: frameBody})
+		} else {
+			s.push(frame{kind: frameObject})
+		}
+		s.lastSig = c
+
+		return i + 1
+	case c == '(':
+		s.push(frame{kind: frameParen})
+		s.lastSig = c
+
+		return i + 1
+	case c == '[':
+		s.push(frame{kind: frameBracket})
+		s.lastSig = c
+
+		return i + 1
+	case c == '}' || c == ')' || c == ']':
+		s.pop()
+		s.lastSig = c
+
+		return i + 1
+	case c == '=':
+		s.lineEquals = true
+	case literalPercent(src[i:]):
+		s.lastSig = c
+
+		return i + 2
+	case c == '%' && !moduloOperator(src[i:]):
+		if n := verbLen(src[i:]); n > 0 {
+			s.verb(i, i+n, s.expressionContext(i, i+n))
+			s.lastSig = 'a'
+
+			return i + n
+		}
+	}
+
+	s.lastSig = c
+
+	return i + 1
+}
+
+// scanString scans the character at i in a quoted template, returning where to continue
+func (s *scanner) scanString(i int) int {
+	src := s.src
+
+	switch {
+	case src[i] == '\\':
+		return min(i+2, len(src))
+	case src[i] == '"':
+		s.pop()
+		s.lastSig = '"'
+
+		return i + 1
+	case src[i] == '\n':
+		// quoted templates can not span lines, leave the broken string so the rest is still scanned
+		s.pop()
+		s.newline(i)
+
+		return i + 1
+	}
+
+	return s.scanTemplate(i)
+}
+
+// scanHeredoc scans the character at i in a heredoc, returning where to continue
+func (s *scanner) scanHeredoc(i int) int {
+	src := s.src
+
+	if s.heredocLine {
+		s.heredocLine = false
+
+		end := strings.IndexByte(src[i:], '\n')
+		if end < 0 {
+			end = len(src) - i
+		}
+		if strings.TrimSpace(src[i:i+end]) == s.stack[len(s.stack)-1].terminator {
+			s.pop()
+			s.lastSig = 'a'
+
+			return i + end
+		}
+	}
+
+	if src[i] == '\n' {
+		s.newline(i)
+		s.heredocLine = true
+
+		return i + 1
+	}
+
+	return s.scanTemplate(i)
+}
+
+// scanTemplate scans the character at i in the literal text of a template, returning where to
+// continue
+func (s *scanner) scanTemplate(i int) int {
+	src := s.src
+
+	switch {
+	case strings.HasPrefix(src[i:], "$${"), strings.HasPrefix(src[i:], "%%{"):
+		return i + 3
+	case strings.HasPrefix(src[i:], "${"), strings.HasPrefix(src[i:], "%{"):
+		s.push(frame{kind: frameInterp})
+		s.lastSig = '{'
+
+		return i + 2
+	case literalPercent(src[i:]):
+		return i + 2
+	case src[i] == '%':
+		if n := verbLen(src[i:]); n > 0 {
+			s.verb(i, i+n, ContextTemplate)
+
+			return i + n
+		}
+	}
+
+	return i + 1
+}
+
+// comment records the verbs in the comment src[start:end]
+func (s *scanner) comment(start, end int) {
+	for i := start; i < end; i++ {
+		if s.src[i] == '\n' {
+			s.newline(i)
+		}
+		if s.src[i] != '%' {
+			continue
+		}
+		if literalPercent(s.src[i:end]) {
+			i++
+
+			continue
+		}
+		if n := verbLen(s.src[i:end]); n > 0 {
+			s.verb(i, i+n, ContextComment)
+			i += n - 1
+		}
+	}
+}
+
+// expressionContext returns the context of the verb src[start:end] found outside of any template
+func (s *scanner) expressionContext(start, end int) Context {
+	src := s.src
+
+	// the rest of its line, a line ending in \r\n ends at the \r
+	rest := src[end:]
+	if eol := strings.IndexByte(rest, '\n'); eol >= 0 {
+		rest = rest[:eol]
+	}
+	rest = strings.TrimSuffix(rest, "\r")
+
+	inBody := true   // only in block bodies
+	inBraces := true // only in block bodies or objects
+	for _, f := range s.stack {
+		if f.kind != frameBody {
+			inBody = false
+		}
+		if f.kind != frameBody && f.kind != frameObject {
+			inBraces = false
+		}
+	}
+
+	before := strings.TrimLeft(src[s.lineStart:start], " \t")
+	if inBraces && before == "" && rest == "" {
+		return ContextLine
+	}
+
+	if inBody && !s.lineEquals && before != "" && (src[start-1] == ' ' || src[start-1] == '\t') {
+		next := strings.TrimLeft(rest, " \t")
+		if strings.HasPrefix(next, "{") || strings.HasPrefix(next, "\"") {
+			return ContextLabel
+		}
+	}
+
+	return ContextExpression
+}
+
+func (s *scanner) verb(start, end int, context Context) {
+	s.verbs = append(s.verbs, Verb{
+		Text:    s.src[start:end],
+		Start:   start,
+		End:     end,
+		Line:    s.lineNumber,
+		Column:  utf8.RuneCountInString(s.src[s.lineStart:start]) + 1,
+		Context: context,
+	})
+}
+
+// heredocStart returns the closing marker of the heredoc opened at the start of src, and the offset
+// its first line starts at, or 0 if src does not start a heredoc
+func heredocStart(src string) (string, int) {
+	i := 2
+	if i < len(src) && src[i] == '-' {
+		i++
+	}
+
+	start := i
+	for i < len(src) && (isIdentByte(src[i]) || (i > start && src[i] == '-')) {
+		i++
+	}
+	if i == start {
+		return "", 0
+	}
+	marker := src[start:i]
+
+	if strings.HasPrefix(src[i:], "\r\n") {
+		return marker, i + 2
+	}
+	if strings.HasPrefix(src[i:], "\n") {
+		return marker, i + 1
+	}
+
+	return "", 0
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= utf8.RuneSelf
+}
+
+// verbLetters are the verbs of the fmt package
+const verbLetters = "vTtbcdoOqxXUeEfFgGspw"
+
+// verbLen returns the length of the go format verb at the start of s, or 0 if there is not one. A
+// verb is a % followed by, as in the fmt package, any flags (+-# 0), an optional argument index
+// ([n]), an optional width (digits or *), an optional precision (. and digits or *, each * can have
+// an argument index before it), an optional argument index, and a verb letter. A literal %% is not a
+// verb, see literalPercent.
+func verbLen(s string) int {
+	if len(s) < 2 || s[0] != '%' {
+		return 0
+	}
+
+	i := 1
+	for i < len(s) && strings.IndexByte("+-# 0", s[i]) >= 0 {
+		i++
+	}
+	i += argIndexLen(s[i:])
+	i += widthLen(s[i:])
+	if i < len(s) && s[i] == '.' {
+		i++
+		i += argIndexLen(s[i:])
+		i += widthLen(s[i:])
+	}
+	i += argIndexLen(s[i:])
+
+	if i < len(s) && strings.IndexByte(verbLetters, s[i]) >= 0 {
+		return i + 1
+	}
+
+	return 0
+}
+
+// moduloOperator reports if the % at the start of s, found in an expression, is the modulo operator
+// of an operand that starts with an identifier or number (count.index % var.n) rather than a verb
+// with the space flag (% d)
+func moduloOperator(s string) bool {
+	return len(s) > 2 && s[1] == ' ' && isIdentByte(s[2])
+}
+
+// literalPercent reports if s starts with %%, the escape for a literal %
+func literalPercent(s string) bool {
+	return strings.HasPrefix(s, "%%")
+}
+
+// widthLen returns the length of the width or precision (digits or *) at the start of s, or 0
+func widthLen(s string) int {
+	if s != "" && s[0] == '*' {
+		return 1
+	}
+
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return i
+}
+
+// argIndexLen returns the length of the explicit argument index ([n]) at the start of s, or 0
+func argIndexLen(s string) int {
+	if len(s) < 3 || s[0] != '[' {
+		return 0
+	}
+
+	i := 1
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i == 1 || i >= len(s) || s[i] != ']' {
+		return 0
+	}
+
+	return i + 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -1,19 +1,98 @@
 package format
 
 import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
 	"github.com/katbyte/terrafmt/lib/fmtverbs"
 	"github.com/sirupsen/logrus"
 )
 
 func FmtVerbBlock(log *logrus.Logger, content, path string) (string, error) {
-	content = fmtverbs.Escape(content)
+	escaped, err := fmtverbs.Escape(content)
+	if err != nil {
+		return "", err
+	}
 
-	fb, err := Block(log, content, path)
+	fb, err := Block(log, escaped.Text, path)
 	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return fb, unescapeParseError(parseErr, content)
+		}
+
 		return fb, err
 	}
 
-	fb = fmtverbs.Unscape(fb)
+	return escaped.Unescape(fb)
+}
+
+// unescapeParseError moves the diagnostics of a block that failed to parse once its verbs were
+// escaped back onto the original content. Escaping never adds or removes lines, so only the columns
+// of lines with verbs move: a position before or after the escaped text keeps its place relative to
+// the start or end of the line, and one inside it is put at the start of the verb.
+func unescapeParseError(e *ParseError, content string) *ParseError {
+	escapedLines, escapedOffsets := splitLines(e.Content)
+	lines, lineOffsets := splitLines(content)
+
+	unescape := func(pos hcl.Pos) hcl.Pos {
+		i := pos.Line - 1
+		if i < 0 || i >= len(lines) || i >= len(escapedLines) {
+			return pos
+		}
+		escaped, line := escapedLines[i], lines[i]
+
+		prefix := 0
+		for prefix < len(escaped) && prefix < len(line) && escaped[prefix] == line[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(escaped)-prefix && suffix < len(line)-prefix && escaped[len(escaped)-1-suffix] == line[len(line)-1-suffix] {
+			suffix++
+		}
+
+		col := pos.Byte - escapedOffsets[i]
+		switch {
+		case col <= prefix:
+		case col >= len(escaped)-suffix:
+			col = len(line) - (len(escaped) - col)
+		default:
+			col = prefix
+		}
+
+		col = max(0, min(col, len(line)))
+
+		return hcl.Pos{Line: pos.Line, Column: utf8.RuneCountInString(line[:col]) + 1, Byte: lineOffsets[i] + col}
+	}
+
+	diags := make(hcl.Diagnostics, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		unescaped := *d
+		if d.Subject != nil {
+			r := *d.Subject
+			r.Start, r.End = unescape(r.Start), unescape(r.End)
+			unescaped.Subject = &r
+		}
+		if d.Context != nil {
+			r := *d.Context
+			r.Start, r.End = unescape(r.Start), unescape(r.End)
+			unescaped.Context = &r
+		}
+		diags = append(diags, &unescaped)
+	}
+
+	return &ParseError{Diagnostics: diags, Content: content}
+}
+
+// splitLines splits s after each newline, returning the lines and the byte offset each starts at
+func splitLines(s string) ([]string, []int) {
+	lines := strings.SplitAfter(s, "\n")
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1])
+	}
 
-	return fb, nil
+	return lines, offsets
 }
@@ -1,6 +1,8 @@
 package format
 
 import (
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -290,3 +292,51 @@ resource "resource" "test" {
 		})
 	}
 }
+
+func TestFmtVerbBlockParseErrorPositions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		block  string
+		line   int
+		column int
+		byte   int
+	}{
+		{
+			name:   "before verb",
+			block:  "resource \"a\" \"b\" {\n  name = \"%s-x\" }}\n}\n",
+			line:   2,
+			column: 17,
+			byte:   35,
+		},
+		{
+			name:   "after escaped verbs",
+			block:  "resource \"a\" \"b\" {\n  x = [%s, %d] ]\n}\n",
+			line:   2,
+			column: 16,
+			byte:   34,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := FmtVerbBlock(common.CreateLogger(io.Discard), test.block, "test")
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a parse error, got %v", err)
+			}
+			if parseErr.Content != test.block {
+				t.Errorf("Expected the error to be for the original block, got:\n%s", parseErr.Content)
+			}
+
+			start := parseErr.Diagnostics[0].Subject.Start
+			if start.Line != test.line || start.Column != test.column || start.Byte != test.byte {
+				t.Errorf("Expected the error at %d:%d (byte %d), got %d:%d (byte %d)", test.line, test.column, test.byte, start.Line, start.Column, start.Byte)
+			}
+		})
+	}
+}
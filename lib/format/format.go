@@ -2,7 +2,6 @@
 package format
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/hashicorp/hcl/v2"
@@ -11,6 +10,17 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ParseError is returned for a block that is not valid HCL. The positions of its diagnostics are
+// relative to Content, the block that was being formatted.
+type ParseError struct {
+	Diagnostics hcl.Diagnostics
+	Content     string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse hcl: %s\n%s", e.Diagnostics.Error(), e.Content)
+}
+
 func Block(log *logrus.Logger, content, path string) (string, error) {
 	b := []byte(content)
 
@@ -18,7 +28,7 @@ func Block(log *logrus.Logger, content, path string) (string, error) {
 	_, syntaxDiags := hclsyntax.ParseConfig(b, path, hcl.Pos{Line: 1, Column: 1})
 
 	if syntaxDiags.HasErrors() {
-		return "", fmt.Errorf("failed to parse hcl: %w\n%s", errors.New(syntaxDiags.Error()), b)
+		return "", &ParseError{Diagnostics: syntaxDiags, Content: content}
 	}
 
 	return string(hclwrite.Format(b)), nil
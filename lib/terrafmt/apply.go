@@ -0,0 +1,155 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/spf13/afero"
+)
+
+// BlockEdit is new text for a block of a file, see ApplyEdits.
+type BlockEdit struct {
+	Number int // 1 based, as BlockResult.Number
+
+	// the lines of the block as the blocks command reports them (for text documents the lines opening
+	// and closing it), when set the block must still be on them
+	StartLine int
+	EndLine   int
+
+	// Original must still be the text of the block, so an edit made for text that has since changed
+	// is never applied even when the block has kept its lines
+	Original string
+
+	Text string
+}
+
+// EditError is the error of edits that do not match the blocks of a file, so none of them were applied.
+type EditError struct {
+	Filename   string
+	Mismatches []string // why each edit that did not match was refused
+}
+
+func (e *EditError) Error() string {
+	return fmt.Sprintf("%s has changed since the edits were made, none were applied: %s", e.Filename, strings.Join(e.Mismatches, "; "))
+}
+
+// ApplyEdits replaces the text of the blocks of filename that edits are for. Every edit is checked
+// against the block it is for first: the block must exist, still have its original text, and still be
+// on the edit's lines when they are set. If any of them do not match an *EditError is returned and
+// the file is left as it is.
+func ApplyEdits(ctx context.Context, fs afero.Fs, filename string, edits []BlockEdit, opts Options) (*FileResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, errors.New("edits can only be applied to a file")
+	}
+
+	byNumber := make(map[int]BlockEdit, len(edits))
+	for _, e := range edits {
+		if _, ok := byNumber[e.Number]; ok {
+			return nil, fmt.Errorf("%s: more than one edit for block %d", filename, e.Number)
+		}
+		byNumber[e.Number] = e
+	}
+
+	// check everything before anything is written, checking again as the file is written stops it
+	// being written should it change in between
+	if _, err := applyEdits(fs, filename, byNumber, opts, true); err != nil {
+		return nil, err
+	}
+
+	return applyEdits(fs, filename, byNumber, opts, false)
+}
+
+func applyEdits(fs afero.Fs, filename string, edits map[int]BlockEdit, opts Options, readOnly bool) (*FileResult, error) {
+	log := opts.logger()
+	result := &FileResult{}
+	var mismatches []string
+	found := map[int]bool{}
+
+	br := blocks.Reader{
+		Log:      log,
+		ReadOnly: readOnly,
+		LineRead: blocks.ReaderPassthrough,
+		BlockRead: func(br *blocks.Reader, _ int, b string, _ bool) error {
+			block := BlockResult{
+				Number:    br.BlockCount,
+				StartLine: br.LineCount - br.BlockCurrentLine,
+				EndLine:   br.LineCount,
+				Original:  b,
+			}
+			block.ContentStart, _ = br.BlockPosition(0)
+			block.ContentEnd, _ = br.BlockPosition(max(len(b)-1, 0))
+
+			text := b
+			if e, ok := edits[block.Number]; ok {
+				found[block.Number] = true
+				if mismatch := editMismatch(e, block); mismatch != "" {
+					mismatches = append(mismatches, mismatch)
+					br.ReadOnly = true
+				} else {
+					text = e.Text
+				}
+			}
+
+			if text != b {
+				block.Status = BlockFormatted
+				result.ChangedBlocks++
+			}
+			block.Formatted = text
+			result.Blocks = append(result.Blocks, block)
+
+			if br.CurrentNodeCursor != nil {
+				if text != b {
+					replaceGoBlock(br, text)
+				}
+
+				return nil
+			}
+
+			// the line closing a block follows its text
+			if text != "" && !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			_, err := br.Writer.Write([]byte(text))
+
+			return err
+		},
+	}
+
+	err := br.DoTheThing(fs, filename, opts.Stdin, opts.Stdout)
+	if err != nil {
+		return result.fromReader(&br), err
+	}
+
+	// there is no later block to stop the file being written for these, so it is the check pass that
+	// catches them
+	for _, n := range slices.Sorted(maps.Keys(edits)) {
+		if !found[n] {
+			mismatches = append(mismatches, fmt.Sprintf("block %d not found", n))
+		}
+	}
+	if len(mismatches) > 0 {
+		return result.fromReader(&br), &EditError{Filename: filename, Mismatches: mismatches}
+	}
+
+	return result.fromReader(&br), nil
+}
+
+// editMismatch returns why e can not be applied to block, or nothing when it can
+func editMismatch(e BlockEdit, block BlockResult) string {
+	if (e.StartLine != 0 && e.StartLine != block.StartLine) || (e.EndLine != 0 && e.EndLine != block.EndLine) {
+		return fmt.Sprintf("block %d is on lines %d-%d, not %d-%d", block.Number, block.StartLine, block.EndLine, e.StartLine, e.EndLine)
+	}
+	if e.Original != block.Original {
+		return fmt.Sprintf("block %d does not have its original text", block.Number)
+	}
+
+	return ""
+}
@@ -0,0 +1,107 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/spf13/afero"
+)
+
+func TestApplyEdits(t *testing.T) {
+	t.Parallel()
+
+	original := "resource \"aws_s3_bucket\" \"example\" {\n  bucket =    \"example\"\n}\n"
+	formatted := "resource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n"
+	testcases := []struct {
+		name     string
+		filename string
+		source   string
+		edits    []BlockEdit
+		expected string
+		mismatch bool
+	}{
+		{
+			name:     "markdown",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 1, StartLine: 3, EndLine: 7, Original: original, Text: formatted}},
+			expected: formattedMarkdown,
+		},
+		{
+			name:     "go",
+			filename: "main.go",
+			source:   "package main\n\nconst config = `\n" + original + "`\n",
+			edits:    []BlockEdit{{Number: 1, StartLine: 3, EndLine: 7, Original: original, Text: formatted}},
+			expected: "package main\n\nconst config = `\n" + formatted + "`\n",
+		},
+		{
+			name:     "text without a final newline",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 2, Original: formatted, Text: "locals {}"}},
+			expected: strings.Replace(unformattedMarkdown, "```hcl\n"+formatted+"```", "```hcl\nlocals {}\n```", 1),
+		},
+		{
+			name:     "moved block",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 1, StartLine: 3, EndLine: 7, Original: original, Text: formatted}, {Number: 2, StartLine: 8, EndLine: 13, Original: formatted, Text: "locals {}\n"}},
+			mismatch: true,
+		},
+		{
+			name:     "changed block",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 1, Original: "wrong", Text: formatted}},
+			mismatch: true,
+		},
+		{
+			// the edit was made for text that has since changed without moving the block
+			name:     "changed block on the same lines",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 1, StartLine: 3, EndLine: 7, Original: strings.Replace(original, "example", "renamed", 1), Text: formatted}},
+			mismatch: true,
+		},
+		{
+			name:     "missing block",
+			filename: "README.md",
+			source:   unformattedMarkdown,
+			edits:    []BlockEdit{{Number: 1, Original: original, Text: formatted}, {Number: 4, Original: formatted, Text: formatted}},
+			mismatch: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newTestFs(t, map[string]string{testcase.filename: testcase.source})
+
+			_, err := ApplyEdits(context.Background(), fs, testcase.filename, testcase.edits, Options{})
+
+			var editErr *EditError
+			if testcase.mismatch != errors.As(err, &editErr) {
+				t.Fatalf("Expected a mismatch %t, got %v", testcase.mismatch, err)
+			}
+			if !testcase.mismatch && err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			expected := testcase.expected
+			if testcase.mismatch {
+				expected = testcase.source
+			}
+			data, err := afero.ReadFile(fs, testcase.filename)
+			if err != nil {
+				t.Fatalf("Error reading %q: %s", testcase.filename, err)
+			}
+			if string(data) != expected {
+				t.Errorf("File differs:\n%s", diff.Diff(string(data), expected))
+			}
+		})
+	}
+}
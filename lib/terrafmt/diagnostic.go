@@ -0,0 +1,109 @@
+package terrafmt
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/format"
+)
+
+// Diagnostic is a problem found in a block, positioned in the host file rather than the block.
+type Diagnostic struct {
+	Severity string // error or warning
+	Summary  string
+	Detail   string
+	Pos      token.Position
+	Source   string // the line of the host file Pos is on, without its line ending
+}
+
+// String returns the diagnostic as file:line:col: summary; detail.
+func (d Diagnostic) String() string {
+	msg := d.Summary
+	if d.Detail != "" {
+		msg += "; " + d.Detail
+	}
+
+	return fmt.Sprintf("%s: %s", d.Pos, msg)
+}
+
+// Annotated returns the diagnostic compiler style, its position, severity, and message followed by
+// the host source line with a caret under the position:
+//
+//	main.go:17:3: error: Argument or block definition required; An argument or block definition is required here.
+//	  %s
+//	  ^
+func (d Diagnostic) Annotated() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %s: %s", d.Pos, d.Severity, d.Summary)
+	if d.Detail != "" {
+		b.WriteString("; " + d.Detail)
+	}
+	b.WriteString("\n" + d.Source + "\n")
+
+	// keep any tabs so the caret lines up however wide they are shown
+	col := min(max(d.Pos.Column-1, 0), len(d.Source))
+	for _, r := range d.Source[:col] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteString("^\n")
+
+	return b.String()
+}
+
+// ParseError is the error of a block that is not valid HCL.
+type ParseError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	switch len(e.Diagnostics) {
+	case 0:
+		return "failed to parse hcl"
+	case 1:
+		return "failed to parse hcl: " + e.Diagnostics[0].String()
+	default:
+		return fmt.Sprintf("failed to parse hcl: %s, and %d other diagnostic(s)", e.Diagnostics[0], len(e.Diagnostics)-1)
+	}
+}
+
+// hostParseError positions the diagnostics of the current block's parse error in the host file, other
+// errors are returned as is
+func hostParseError(br *blocks.Reader, err error) error {
+	var parseErr *format.ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+
+	diags := make([]Diagnostic, 0, len(parseErr.Diagnostics))
+	for _, d := range parseErr.Diagnostics {
+		offset := 0
+		if d.Subject != nil {
+			offset = d.Subject.Start.Byte
+		}
+
+		severity := "error"
+		if d.Severity == hcl.DiagWarning {
+			severity = "warning"
+		}
+
+		pos, source := br.BlockPosition(offset)
+		diags = append(diags, Diagnostic{
+			Severity: severity,
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+			Pos:      pos,
+			Source:   source,
+		})
+	}
+
+	return &ParseError{Diagnostics: diags}
+}
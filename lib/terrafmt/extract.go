@@ -0,0 +1,236 @@
+package terrafmt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/fmtverbs"
+	"github.com/spf13/afero"
+)
+
+// ManifestName is the name of the manifest Extract writes alongside the blocks.
+const ManifestName = "terrafmt-manifest.json"
+
+// Manifest records where each block Extract wrote came from, so Inject can put it back.
+type Manifest struct {
+	Blocks []ExtractedBlock `json:"blocks"`
+}
+
+// ExtractedBlock is a block Extract wrote to its own file.
+type ExtractedBlock struct {
+	File      string `json:"file"` // the host file the block is in
+	Func      string `json:"func,omitempty"`
+	Number    int    `json:"block_number"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+
+	Path string `json:"path"` // the file the block was written to, relative to the manifest
+
+	// FmtVerbs is set when the go format verbs in the written block were escaped (Options.FmtCompat),
+	// Inject puts them back by escaping Original again
+	FmtVerbs bool `json:"fmtverbs,omitempty"`
+
+	// Indented is set when the block is indented to match its host document (an rst directive or
+	// markdown list item) and was written without that indentation, Inject indents it again
+	Indented bool `json:"indented,omitempty"`
+
+	// Original is the text of the block when it was extracted, Inject refuses to replace a block that
+	// no longer has it
+	Original string `json:"original"`
+}
+
+// Extract writes each block of filenames to its own .tf file below dir, named by its host file, go
+// function, and number, and the manifest Inject reads to put them back to dir/ManifestName. With
+// opts.FmtCompat go format verbs are escaped as fmt does. The files are read one at a time, one that
+// can not be read is reported in the returned error after the others are extracted.
+func Extract(ctx context.Context, fs afero.Fs, filenames []string, dir string, opts Options) (*Manifest, error) {
+	log := opts.logger()
+	manifest := &Manifest{Blocks: []ExtractedBlock{}}
+
+	var errs *multierror.Error
+	for _, filename := range filenames {
+		if err := ctx.Err(); err != nil {
+			errs = multierror.Append(errs, err)
+
+			break
+		}
+
+		src, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+
+			continue
+		}
+
+		for b, err := range blocks.ScanFile(filename, src, blocks.KindForFile(filename)) {
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s: %w", filename, err))
+
+				break
+			}
+
+			extracted := ExtractedBlock{
+				File:      filename,
+				Func:      b.Func,
+				Number:    b.Number,
+				StartLine: b.StartLine,
+				EndLine:   b.EndLine,
+				Path:      extractedPath(b),
+				Original:  b.Text,
+			}
+
+			// tools that normalise the written file would strip the host's indentation anyway
+			text := b.Text
+			if b.Host.Indented {
+				text = dedent(text)
+				extracted.Indented = true
+			}
+			if opts.FmtCompat {
+				if escaped, err := fmtverbs.Escape(text); err == nil {
+					text = escaped.Text
+					extracted.FmtVerbs = true
+				} else {
+					log.Warnf("block %d @ %s:%d: format verbs left as they are: %v", b.Number, filename, b.StartLine, err)
+				}
+			}
+
+			target := filepath.Join(dir, filepath.FromSlash(extracted.Path))
+			if err := fs.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return nil, err
+			}
+			if err := afero.WriteFile(fs, target, []byte(text), 0o644); err != nil {
+				return nil, err
+			}
+
+			manifest.Blocks = append(manifest.Blocks, extracted)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := afero.WriteFile(fs, filepath.Join(dir, ManifestName), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	return manifest, errs.ErrorOrNil()
+}
+
+// extractedPath returns the slash separated path, relative to the extract directory, a block is
+// written to: its host file's path followed by its go function, if any, and number, e.g.
+// internal/foo_test.go.testAccFoo_basic.2.tf
+func extractedPath(b blocks.Block) string {
+	p := filepath.Clean(b.File)
+
+	// keep absolute and parent paths inside the directory
+	p = strings.TrimLeft(filepath.ToSlash(strings.TrimPrefix(p, filepath.VolumeName(p))), "/")
+	elems := strings.Split(p, "/")
+	for i, e := range elems {
+		if e == ".." {
+			elems[i] = "__"
+		}
+	}
+
+	name := strings.Join(elems, "/")
+	if b.Func != "" {
+		name += "." + b.Func
+	}
+
+	return name + "." + strconv.Itoa(b.Number) + ".tf"
+}
+
+// ReadManifest reads the manifest Extract wrote.
+func ReadManifest(fs afero.Fs, filename string) (*Manifest, error) {
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error reading manifest %s: %w", filename, err)
+	}
+
+	return &manifest, nil
+}
+
+// Inject puts the blocks Extract wrote, as they are now, back into their host files, reading the
+// manifest from manifestFile. Like ApplyEdits every block of a file must still have its original
+// text, if any of them do not the file is left as it is and an *EditError is returned for it, along
+// with the errors of any other files once they have all been injected, one at a time.
+func Inject(ctx context.Context, fs afero.Fs, manifestFile string, opts Options) ([]*FileResult, error) {
+	manifest, err := ReadManifest(fs, manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(manifestFile)
+	edits := map[string][]BlockEdit{}
+	for _, b := range manifest.Blocks {
+		if b.File == "" || b.Number < 1 || b.Path == "" {
+			return nil, fmt.Errorf("error reading manifest %s: a block has no file, block_number, or path", manifestFile)
+		}
+
+		data, err := afero.ReadFile(fs, filepath.Join(dir, filepath.FromSlash(b.Path)))
+		if err != nil {
+			return nil, err
+		}
+
+		text := string(data)
+		if b.FmtVerbs {
+			// escaping is repeatable, so the placeholders are made again from the original
+			original := b.Original
+			if b.Indented {
+				original = dedent(original)
+			}
+			escaped, err := fmtverbs.Escape(original)
+			if err != nil {
+				return nil, err
+			}
+			if text, err = escaped.Unescape(text); err != nil {
+				return nil, fmt.Errorf("%s: %w", b.Path, err)
+			}
+		}
+		if b.Indented {
+			text = indentToOriginalLevel(dedent(text), b.Original)
+		}
+
+		edits[b.File] = append(edits[b.File], BlockEdit{
+			Number:   b.Number,
+			Original: b.Original,
+			Text:     text,
+		})
+	}
+
+	var results []*FileResult
+	var errs *multierror.Error
+	for _, filename := range slices.Sorted(maps.Keys(edits)) {
+		if err := ctx.Err(); err != nil {
+			errs = multierror.Append(errs, err)
+
+			break
+		}
+
+		res, err := ApplyEdits(ctx, fs, filename, edits[filename], opts)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+
+			continue
+		}
+		results = append(results, res)
+	}
+
+	return results, errs.ErrorOrNil()
+}
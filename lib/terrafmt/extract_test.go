@@ -0,0 +1,147 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/format"
+	"github.com/kylelemons/godebug/diff"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+func TestExtractInject(t *testing.T) {
+	t.Parallel()
+
+	goSrc := "package a\n\nfunc testAccBucket_basic(name string) string {\n\treturn fmt.Sprintf(`\nresource \"aws_s3_bucket\" \"test\" {\n  bucket =    %q\n}\n`, name, name)\n}\n"
+	fs := newTestFs(t, map[string]string{
+		"docs/README.md":     unformattedMarkdown,
+		"internal/a_test.go": goSrc,
+	})
+
+	manifest, err := Extract(context.Background(), fs, []string{"docs/README.md", "internal/a_test.go"}, "out", Options{FmtCompat: true})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	var paths []string
+	for _, b := range manifest.Blocks {
+		paths = append(paths, b.Path)
+	}
+	expectedPaths := []string{"docs/README.md.1.tf", "docs/README.md.2.tf", "docs/README.md.3.tf", "internal/a_test.go.testAccBucket_basic.1.tf"}
+	if !slices.Equal(paths, expectedPaths) {
+		t.Fatalf("Expected blocks %v, got %v", expectedPaths, paths)
+	}
+
+	read, err := ReadManifest(fs, filepath.Join("out", ManifestName))
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if len(read.Blocks) != len(manifest.Blocks) {
+		t.Errorf("Expected the manifest to have %d blocks, got %d", len(manifest.Blocks), len(read.Blocks))
+	}
+
+	goBlock := filepath.Join("out", "internal", "a_test.go.testAccBucket_basic.1.tf")
+	escaped, err := afero.ReadFile(fs, goBlock)
+	if err != nil {
+		t.Fatalf("Error reading %q: %s", goBlock, err)
+	}
+	if _, err := format.Block(logrus.New(), string(escaped), goBlock); err != nil {
+		t.Errorf("Expected the escaped block to be valid HCL, got %v:\n%s", err, escaped)
+	}
+
+	// edit the extracted blocks as another tool would, the escaped verbs are left alone
+	edited := strings.Replace(string(escaped), "bucket =    ", "bucket = ", 1)
+	if err := afero.WriteFile(fs, goBlock, []byte(edited), 0o644); err != nil {
+		t.Fatalf("Error writing %q: %s", goBlock, err)
+	}
+	mdBlock := filepath.Join("out", "docs", "README.md.1.tf")
+	if err := afero.WriteFile(fs, mdBlock, []byte("resource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n"), 0o644); err != nil {
+		t.Fatalf("Error writing %q: %s", mdBlock, err)
+	}
+
+	if _, err := Inject(context.Background(), fs, filepath.Join("out", ManifestName), Options{}); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	for filename, expected := range map[string]string{
+		"docs/README.md":     formattedMarkdown,
+		"internal/a_test.go": strings.Replace(goSrc, "bucket =    %q", "bucket = %q", 1),
+	} {
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			t.Fatalf("Error reading %q: %s", filename, err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s differs:\n%s", filename, diff.Diff(string(data), expected))
+		}
+	}
+
+	// the markdown blocks were injected, so no longer have the text they were extracted with
+	_, err = Inject(context.Background(), fs, filepath.Join("out", ManifestName), Options{})
+	var editErr *EditError
+	if !errors.As(err, &editErr) || editErr.Filename != "docs/README.md" {
+		t.Errorf("Expected the changed docs/README.md to be refused, got %v", err)
+	}
+}
+
+func TestExtractInjectIndented(t *testing.T) {
+	t.Parallel()
+
+	source := "Example\n\n.. code:: terraform\n\n   resource \"aws_s3_bucket\" \"example\" {\n     bucket =    \"example\"\n   }\n\nText\n"
+	fs := newTestFs(t, map[string]string{"index.rst": source})
+
+	manifest, err := Extract(context.Background(), fs, []string{"index.rst"}, "out", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if len(manifest.Blocks) != 1 || !manifest.Blocks[0].Indented {
+		t.Fatalf("Expected one indented block, got %+v", manifest.Blocks)
+	}
+
+	// the block is written as a .tf file would be, so normalising it does not change its indentation
+	block := filepath.Join("out", "index.rst.1.tf")
+	data, err := afero.ReadFile(fs, block)
+	if err != nil {
+		t.Fatalf("Error reading %q: %s", block, err)
+	}
+	if expected := "resource \"aws_s3_bucket\" \"example\" {\n  bucket =    \"example\"\n}\n\n"; string(data) != expected {
+		t.Errorf("Expected the block to be written without its indentation:\n%s", diff.Diff(string(data), expected))
+	}
+
+	formatted, err := format.Block(logrus.New(), string(data), block)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if err := afero.WriteFile(fs, block, []byte(formatted), 0o644); err != nil {
+		t.Fatalf("Error writing %q: %s", block, err)
+	}
+
+	if _, err := Inject(context.Background(), fs, filepath.Join("out", ManifestName), Options{}); err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	data, err = afero.ReadFile(fs, "index.rst")
+	if err != nil {
+		t.Fatalf("Error reading %q: %s", "index.rst", err)
+	}
+	if expected := strings.Replace(source, "bucket =    ", "bucket = ", 1); string(data) != expected {
+		t.Errorf("index.rst differs:\n%s", diff.Diff(string(data), expected))
+	}
+}
+
+func TestExtractedPath(t *testing.T) {
+	t.Parallel()
+
+	if p := extractedPath(blocks.Block{File: "/abs/../x/main.go", Func: "testFoo", Number: 2}); p != "x/main.go.testFoo.2.tf" {
+		t.Errorf("Unexpected path for an absolute file: %s", p)
+	}
+	if p := extractedPath(blocks.Block{File: "../docs/README.md", Number: 1}); p != "__/docs/README.md.1.tf" {
+		t.Errorf("Unexpected path for a parent file: %s", p)
+	}
+}
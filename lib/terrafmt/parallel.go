@@ -0,0 +1,97 @@
+package terrafmt
+
+import (
+	"context"
+	"runtime"
+	"slices"
+)
+
+// ProcessFiles calls process for every file in filenames on up to parallelism workers (GOMAXPROCS
+// when parallelism is less than 1), and then done with its result. done is called from the calling
+// goroutine in sorted file order, as soon as that file and all of the ones before it are processed,
+// so anything it writes comes out the same no matter how the files were scheduled.
+//
+// Once ctx is cancelled no more files are started: the ones already being processed are finished
+// and passed to done, the rest are not, and ctx's error is returned.
+func ProcessFiles[T any](ctx context.Context, filenames []string, parallelism int, process func(filename string) T, done func(filename string, result T)) error {
+	filenames = slices.Clone(filenames)
+	slices.Sort(filenames)
+
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	parallelism = min(parallelism, len(filenames))
+
+	type job struct {
+		result  T
+		skipped bool // ctx was cancelled before the file was started
+		done    chan struct{}
+	}
+	jobs := make([]*job, len(filenames))
+	for i := range jobs {
+		jobs[i] = &job{done: make(chan struct{})}
+	}
+
+	// dispatched is the number of files handed to the workers, only read once dispatching is done
+	queue := make(chan int)
+	dispatching := make(chan struct{})
+	dispatched := 0
+	go func() {
+		defer close(dispatching)
+		defer close(queue)
+		for i := range filenames {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			select {
+			case queue <- i:
+				dispatched++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for range parallelism {
+		go func() {
+			for i := range queue {
+				j := jobs[i]
+				if ctx.Err() != nil {
+					j.skipped = true
+				} else {
+					j.result = process(filenames[i])
+				}
+				close(j.done)
+			}
+		}()
+	}
+
+	cancelled := false
+	for i, j := range jobs {
+		select {
+		case <-j.done:
+		case <-dispatching:
+			// files that were never handed out are not waited for, the ones that were are finished
+			if i < dispatched {
+				<-j.done
+			} else {
+				j.skipped = true
+			}
+		}
+		if j.skipped {
+			cancelled = true
+			continue
+		}
+
+		done(filenames[i], j.result)
+	}
+
+	if cancelled {
+		return ctx.Err()
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProcessFilesOrder(t *testing.T) {
+	t.Parallel()
+
+	filenames := []string{"d.md", "b.md", "e.md", "a.md", "c.md"}
+
+	var order []string
+	err := ProcessFiles(context.Background(), filenames, 3,
+		func(filename string) string {
+			// finish the files in the reverse of the order they are expected to be done in
+			time.Sleep(time.Duration('f'-filename[0]) * 5 * time.Millisecond)
+			return filename + " done"
+		},
+		func(_ string, result string) {
+			order = append(order, result)
+		},
+	)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	expected := []string{"a.md done", "b.md done", "c.md done", "d.md done", "e.md done"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected results %v, got %v", expected, order)
+	}
+}
+
+func TestProcessFilesCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var processed []string
+	err := ProcessFiles(ctx, []string{"a.md", "b.md", "c.md", "d.md"}, 1,
+		func(filename string) string {
+			if filename == "b.md" {
+				cancel()
+			}
+			return filename
+		},
+		func(filename string, _ string) {
+			processed = append(processed, filename)
+		},
+	)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+	// the file being processed when cancelled is finished, the rest are never started
+	if expected := []string{"a.md", "b.md"}; !reflect.DeepEqual(processed, expected) {
+		t.Errorf("Expected processed files %v, got %v", expected, processed)
+	}
+}
@@ -0,0 +1,412 @@
+package terrafmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/kylelemons/godebug/diff"
+)
+
+// DefaultPatchContext is the number of unchanged lines shown around each change of a patch, as for
+// diff -u.
+const DefaultPatchContext = 3
+
+// FormattedSource returns the document with its blocks formatted: what FormatFile writes, or would
+// write for a DiffFile result (without FixFinishLines).
+func (r *FileResult) FormattedSource() ([]byte, error) {
+	formatted := make(map[int]BlockResult, len(r.Blocks))
+	for _, b := range r.Blocks {
+		if b.Status == BlockNeedsFormatting || b.Status == BlockFormatted {
+			formatted[b.Number] = b
+		}
+	}
+	if len(formatted) == 0 {
+		return r.Source, nil
+	}
+
+	return blocks.Rewrite(r.Source, r.Kind, func(b blocks.Block) (string, error) {
+		fb, ok := formatted[b.Number]
+		if !ok {
+			return b.Text, nil
+		}
+		if fb.Original != b.Text {
+			return "", fmt.Errorf("%s changed while it was being read", r.Filename)
+		}
+
+		return fb.Formatted, nil
+	})
+}
+
+// Patch returns a unified diff of the document that formatting its blocks makes, with contextLines
+// unchanged lines around each change, or nothing when no block changes. The paths are relative to
+// root (the working directory when empty) and prefixed with a/ and b/, so it can be applied from root
+// with git apply or patch -p1. It is an error for the file not to be below root.
+func (r *FileResult) Patch(contextLines int, root string) ([]byte, error) {
+	if !r.Changed() {
+		return nil, nil
+	}
+
+	path, err := patchPath(r.Filename, root)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := r.FormattedSource()
+	if err != nil {
+		return nil, err
+	}
+
+	return unifiedDiff("a/"+path, "b/"+path, r.Source, formatted, max(contextLines, 0)), nil
+}
+
+// patchPath returns the path of filename relative to root, with / separators
+func patchPath(filename, root string) (string, error) {
+	if root == "" {
+		root = "."
+	}
+
+	absFile, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absRoot, absFile)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is not below the patch root %s", filename, root)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// patchLine is a line of a unified diff: its op (' ', '-', or '+') and its text including any newline
+type patchLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff from a to b, nothing when they are the same
+func unifiedDiff(aName, bName string, a, b []byte, contextLines int) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	// godebug gives the added lines of a change before the deleted ones, a unified diff has them after
+	var lines, added []patchLine
+	for _, c := range diff.DiffChunks(splitLinesKeepEnds(a), splitLinesKeepEnds(b)) {
+		for _, l := range c.Deleted {
+			lines = append(lines, patchLine{'-', l})
+		}
+		for _, l := range c.Added {
+			added = append(added, patchLine{'+', l})
+		}
+		if len(c.Equal) > 0 {
+			lines = append(lines, added...)
+			added = added[:0]
+		}
+		for _, l := range c.Equal {
+			lines = append(lines, patchLine{' ', l})
+		}
+	}
+	lines = append(lines, added...)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	// the a and b line numbers before each line
+	aLine, bLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.op != '+' {
+			aLine[i+1]++
+		}
+		if l.op != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		// a hunk takes in the changes that follow within twice the context of each other
+		start := max(i-contextLines, 0)
+		end := i
+		for {
+			for end < len(lines) && lines[end].op != ' ' {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*contextLines {
+				end = min(end+contextLines, len(lines))
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+		for _, l := range lines[start:end] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return out.Bytes()
+}
+
+// hunkRange returns the start,count of the lines after from up to and including to, an empty range
+// starts at the line before it
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+func splitLinesKeepEnds(src []byte) []string {
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// FilePatch is the changes a unified diff makes to a single file.
+type FilePatch struct {
+	Filename string // from the +++ line, without its b/ prefix, always a local path
+	Hunks    []Hunk
+}
+
+// Hunk is a single @@ section of a unified diff. Lines keep their ' ', '-', or '+' op and newline,
+// a line at the end of a file without a newline has none.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []string
+}
+
+// ParsePatch parses the file patches of a unified diff, as written by diff -u, git diff, or Patch.
+// Anything before or between the files (e.g. git's diff and index lines) is ignored.
+func ParsePatch(data []byte) ([]FilePatch, error) {
+	lines := splitLinesKeepEnds(data)
+
+	var patches []FilePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 == len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+
+		name := patchFilename(lines[i+1])
+		if name == "/dev/null" || patchFilename(lines[i]) == "/dev/null" {
+			return nil, fmt.Errorf("line %d: creating or deleting files is not supported", i+1)
+		}
+		p := FilePatch{Filename: strings.TrimPrefix(name, "b/")}
+
+		// a patch only changes the files below where it is applied, like git apply
+		if !filepath.IsLocal(filepath.FromSlash(p.Filename)) {
+			return nil, fmt.Errorf("line %d: %s is not below the working directory", i+2, p.Filename)
+		}
+		i += 2
+
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			var h Hunk
+			if err := parseHunkHeader(lines[i], &h); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			i++
+
+			// the counts say where a hunk ends, a line that is blank is also taken as context
+			oldLines, newLines := 0, 0
+			for i < len(lines) && (oldLines < h.OldLines || newLines < h.NewLines) {
+				l := lines[i]
+				if l == "\n" {
+					l = " \n"
+				}
+
+				switch l[0] {
+				case ' ':
+					oldLines++
+					newLines++
+				case '-':
+					oldLines++
+				case '+':
+					newLines++
+				default:
+					return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", i+1, strings.TrimSuffix(l, "\n"))
+				}
+				h.Lines = append(h.Lines, l)
+				i++
+
+				if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+					h.Lines[len(h.Lines)-1] = strings.TrimSuffix(h.Lines[len(h.Lines)-1], "\n")
+					i++
+				}
+			}
+			if oldLines != h.OldLines || newLines != h.NewLines {
+				return nil, fmt.Errorf("line %d: hunk ends early", i)
+			}
+
+			p.Hunks = append(p.Hunks, h)
+		}
+		i--
+
+		patches = append(patches, p)
+	}
+
+	if len(patches) == 0 {
+		return nil, errors.New("no file patches found")
+	}
+
+	return patches, nil
+}
+
+// patchFilename returns the file name of a --- or +++ line, without any timestamp after it
+func patchFilename(line string) string {
+	name := strings.TrimSuffix(line[4:], "\n")
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+
+	return strings.TrimPrefix(name, "a/")
+}
+
+var hunkHeaderMatcher = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+func parseHunkHeader(line string, h *Hunk) error {
+	m := hunkHeaderMatcher.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("invalid hunk header: %q", strings.TrimSuffix(line, "\n"))
+	}
+
+	number := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+
+		return n
+	}
+	h.OldStart, h.OldLines = number(m[1]), number(m[2])
+	h.NewStart, h.NewLines = number(m[3]), number(m[4])
+
+	return nil
+}
+
+// Apply returns src with the patch's hunks applied. Each hunk must apply exactly where it says, with
+// the lines it removes and its context unchanged.
+func (p FilePatch) Apply(src []byte) ([]byte, error) {
+	lines := splitLinesKeepEnds(src)
+
+	var out strings.Builder
+	pos := 0
+	for _, h := range p.Hunks {
+		// an empty old range is an insertion after its start line
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if start < pos || start > len(lines) {
+			return nil, fmt.Errorf("%s: hunk @@ -%d,%d does not apply, the file has %d lines", p.Filename, h.OldStart, h.OldLines, len(lines))
+		}
+		for _, l := range lines[pos:start] {
+			out.WriteString(l)
+		}
+		pos = start
+
+		for _, l := range h.Lines {
+			op, text := l[0], l[1:]
+			if op != '+' {
+				if pos == len(lines) || lines[pos] != text {
+					return nil, fmt.Errorf("%s: hunk @@ -%d,%d does not apply, line %d has changed", p.Filename, h.OldStart, h.OldLines, pos+1)
+				}
+				pos++
+			}
+			if op != '-' {
+				out.WriteString(text)
+			}
+		}
+	}
+	for _, l := range lines[pos:] {
+		out.WriteString(l)
+	}
+
+	return []byte(out.String()), nil
+}
+
+// Edits returns the edits to the blocks of src, a document of the given kind, that make the same
+// changes as the patch. Each edit has the original text of its block, and the patch must change
+// nothing but the text of blocks.
+func (p FilePatch) Edits(src []byte, kind blocks.Kind) ([]BlockEdit, error) {
+	patched, err := p.Apply(src)
+	if err != nil {
+		return nil, err
+	}
+
+	scanAll := func(src []byte) ([]blocks.Block, error) {
+		var all []blocks.Block
+		for b, err := range blocks.ScanFile(p.Filename, src, kind) {
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, b)
+		}
+
+		return all, nil
+	}
+	before, err := scanAll(src)
+	if err != nil {
+		return nil, err
+	}
+	after, err := scanAll(patched)
+	if err != nil {
+		return nil, fmt.Errorf("%s once patched: %w", p.Filename, err)
+	}
+	if len(before) != len(after) {
+		return nil, fmt.Errorf("%s: the patch changes the number of blocks from %d to %d", p.Filename, len(before), len(after))
+	}
+
+	var edits []BlockEdit
+	texts := make(map[int]string, len(after))
+	for i, b := range before {
+		if after[i].Text != b.Text {
+			edits = append(edits, BlockEdit{Number: b.Number, Original: b.Text, Text: after[i].Text})
+		}
+		texts[b.Number] = after[i].Text
+	}
+
+	// the edits must make the whole of the patch, with nothing left over outside of the blocks
+	edited, err := blocks.Rewrite(src, kind, func(b blocks.Block) (string, error) {
+		return texts[b.Number], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(edited, patched) {
+		return nil, fmt.Errorf("%s: the patch changes lines outside of terraform blocks", p.Filename)
+	}
+
+	return edits, nil
+}
@@ -0,0 +1,319 @@
+package terrafmt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/kylelemons/godebug/diff"
+)
+
+func TestFormattedSource(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		filename  string
+		source    string
+		formatted string
+	}{
+		{
+			name:      "markdown",
+			filename:  "README.md",
+			source:    unformattedMarkdown,
+			formatted: formattedMarkdown,
+		},
+		{
+			name:      "go",
+			filename:  "main.go",
+			source:    "package main\n\nconst config = `\nresource \"aws_s3_bucket\" \"example\" {\n  bucket =    \"example\"\n}\n`\n",
+			formatted: "package main\n\nconst config = `\nresource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n`\n",
+		},
+		{
+			name:      "hcl",
+			filename:  "main.tf",
+			source:    "locals {\n  a =    1\n}\n",
+			formatted: "locals {\n  a = 1\n}\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newTestFs(t, map[string]string{testcase.filename: testcase.source})
+
+			res, err := DiffFile(context.Background(), fs, testcase.filename, Options{})
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			formatted, err := res.FormattedSource()
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if string(formatted) != testcase.formatted {
+				t.Errorf("Formatted source differs:\n%s", diff.Diff(string(formatted), testcase.formatted))
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{"docs/README.md": unformattedMarkdown})
+
+	res, err := DiffFile(context.Background(), fs, "docs/README.md", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	testcases := []struct {
+		name         string
+		contextLines int
+		expected     string
+	}{
+		{
+			name:         "default context",
+			contextLines: DefaultPatchContext,
+			expected: "--- a/docs/README.md\n" +
+				"+++ b/docs/README.md\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" \n" +
+				" ```hcl\n" +
+				" resource \"aws_s3_bucket\" \"example\" {\n" +
+				"-  bucket =    \"example\"\n" +
+				"+  bucket = \"example\"\n" +
+				" }\n" +
+				" ```\n" +
+				" \n",
+		},
+		{
+			name:         "no context",
+			contextLines: 0,
+			expected: "--- a/docs/README.md\n" +
+				"+++ b/docs/README.md\n" +
+				"@@ -5,1 +5,1 @@\n" +
+				"-  bucket =    \"example\"\n" +
+				"+  bucket = \"example\"\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := res.Patch(testcase.contextLines, "")
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if string(patch) != testcase.expected {
+				t.Errorf("Patch differs:\n%s", diff.Diff(string(patch), testcase.expected))
+			}
+		})
+	}
+}
+
+func TestPatchPath(t *testing.T) {
+	t.Parallel()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Error getting the working directory: %s", err)
+	}
+
+	testcases := []struct {
+		filename string
+		root     string
+		expected string // empty for an error
+	}{
+		{filename: "docs/README.md", expected: "docs/README.md"},
+		{filename: "./docs/../main.tf", expected: "main.tf"},
+		{filename: filepath.Join(wd, "docs", "README.md"), expected: "docs/README.md"},
+		{filename: "docs/README.md", root: "docs", expected: "README.md"},
+		{filename: "docs/README.md", root: wd, expected: "docs/README.md"},
+		{filename: "../README.md"},
+		{filename: "main.tf", root: "docs"},
+		{filename: filepath.Join(filepath.Dir(wd), "README.md")},
+	}
+
+	for _, testcase := range testcases {
+		actual, err := patchPath(filepath.FromSlash(testcase.filename), testcase.root)
+		if testcase.expected == "" {
+			if err == nil {
+				t.Errorf("Expected an error for %s below %q, got %q", testcase.filename, testcase.root, actual)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("Got an error when none was expected for %s: %v", testcase.filename, err)
+		} else if actual != testcase.expected {
+			t.Errorf("Expected %q for %s below %q, got %q", testcase.expected, testcase.filename, testcase.root, actual)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		a, b     string
+		context  int
+		expected string
+	}{
+		{
+			name:     "same",
+			a:        "a\nb\n",
+			b:        "a\nb\n",
+			context:  3,
+			expected: "",
+		},
+		{
+			name:    "separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:       "1\nII\n3\n4\n5\n6\n7\nVIII\n9\n",
+			context: 1,
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,3 +1,3 @@\n 1\n-2\n+II\n 3\n" +
+				"@@ -7,3 +7,3 @@\n 7\n-8\n+VIII\n 9\n",
+		},
+		{
+			name:    "merged hunks",
+			a:       "1\n2\n3\n4\n5\n6\n",
+			b:       "1\nII\n3\n4\nV\n6\n",
+			context: 1,
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,6 +1,6 @@\n 1\n-2\n+II\n 3\n 4\n-5\n+V\n 6\n",
+		},
+		{
+			name:    "insertion",
+			a:       "1\n2\n",
+			b:       "1\ninserted\n2\n",
+			context: 0,
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,0 +2,1 @@\n+inserted\n",
+		},
+		{
+			name:    "no newline at end of file",
+			a:       "1\n2",
+			b:       "1\nII",
+			context: 1,
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,2 +1,2 @@\n 1\n-2\n\\ No newline at end of file\n+II\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch := string(unifiedDiff("a", "b", []byte(testcase.a), []byte(testcase.b), testcase.context))
+			if patch != testcase.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", testcase.expected, patch)
+			}
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	t.Parallel()
+
+	patch := "diff --git a/main.tf b/main.tf\n" +
+		"index 1234567..89abcde 100644\n" +
+		"--- a/main.tf\n" +
+		"+++ b/main.tf\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" locals {\n" +
+		"-  a =    1\n" +
+		"+  a = 1\n" +
+		" }\n" +
+		"--- docs/README.md\t2026-10-18 12:00:00\n" +
+		"+++ docs/README.md\t2026-10-18 12:00:01\n" +
+		"@@ -2 +2 @@\n" +
+		"-b\n" +
+		"\\ No newline at end of file\n" +
+		"+B\n" +
+		"\\ No newline at end of file\n"
+
+	patches, err := ParsePatch([]byte(patch))
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("Expected 2 file patches, got %d", len(patches))
+	}
+
+	if patches[0].Filename != "main.tf" || len(patches[0].Hunks) != 1 || len(patches[0].Hunks[0].Lines) != 4 {
+		t.Errorf("Unexpected first file patch: %+v", patches[0])
+	}
+
+	readme := patches[1]
+	if readme.Filename != "docs/README.md" || len(readme.Hunks) != 1 {
+		t.Fatalf("Unexpected second file patch: %+v", readme)
+	}
+	if h := readme.Hunks[0]; h.OldStart != 2 || h.OldLines != 1 || h.NewStart != 2 || h.NewLines != 1 || h.Lines[0] != "-b" || h.Lines[1] != "+B" {
+		t.Errorf("Unexpected hunk: %+v", h)
+	}
+
+	applied, err := readme.Apply([]byte("a\nb"))
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if string(applied) != "a\nB" {
+		t.Errorf("Expected %q, got %q", "a\nB", applied)
+	}
+
+	if _, err := readme.Apply([]byte("a\nc")); err == nil {
+		t.Errorf("Expected an error applying the patch to a changed file")
+	}
+}
+
+func TestParsePatchOutsideWorkingDirectory(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"../main.tf", "b/../../main.tf", "/etc/main.tf"} {
+		patch := "--- " + name + "\n+++ " + name + "\n@@ -1 +1 @@\n-a\n+b\n"
+		if _, err := ParsePatch([]byte(patch)); err == nil || !strings.Contains(err.Error(), "is not below the working directory") {
+			t.Errorf("Expected an error for a patch of %s, got %v", name, err)
+		}
+	}
+}
+
+func TestFilePatchEdits(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{"README.md": unformattedMarkdown})
+
+	res, err := DiffFile(context.Background(), fs, "README.md", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	patch, err := res.Patch(DefaultPatchContext, "")
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	patches, err := ParsePatch(patch)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	edits, err := patches[0].Edits([]byte(unformattedMarkdown), blocks.KindMarkdown)
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+	if len(edits) != 1 || edits[0].Number != 1 || edits[0].Original != res.Blocks[0].Original || edits[0].Text != res.Blocks[0].Formatted {
+		t.Errorf("Expected an edit formatting the first block, got %+v", edits)
+	}
+
+	outside := FilePatch{Filename: "README.md", Hunks: []Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []string{"-# Example\n", "+# Changed\n"}}}}
+	if _, err := outside.Edits([]byte(unformattedMarkdown), blocks.KindMarkdown); err == nil || !strings.Contains(err.Error(), "outside of terraform blocks") {
+		t.Errorf("Expected an error for a patch changing lines outside of the blocks, got %v", err)
+	}
+}
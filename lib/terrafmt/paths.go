@@ -0,0 +1,155 @@
+package terrafmt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/afero"
+)
+
+// Files returns the files to process for path: path itself if it is a file, or every file below it
+// whose base name matches pattern (all of them when pattern is empty) if it is a directory, sorted.
+// `.terraform` directories and `.terraform.lock.hcl` files are skipped. An empty path returns a single
+// empty filename, for stdin.
+func Files(fs afero.Fs, path, pattern string) ([]string, error) {
+	if path == "" {
+		return []string{""}, nil
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading path (%s): %w", path, err)
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var filenames []string
+
+	err = afero.Walk(fs, path,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				// modules and providers downloaded by terraform init are not ours to format
+				if info.Name() == ".terraform" {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			// the dependency lock file is written by terraform init, its layout is terraform's
+			if info.Name() == ".terraform.lock.hcl" {
+				return nil
+			}
+
+			if pattern == "" {
+				filenames = append(filenames, path)
+
+				return nil
+			}
+
+			matched, err := filepath.Match(pattern, filepath.Base(path))
+			if err != nil {
+				return err
+			}
+
+			if matched {
+				filenames = append(filenames, path)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error walking path (%s): %w", path, err)
+	}
+
+	return filenames, nil
+}
+
+// Result describes all of the files processed for a path.
+type Result struct {
+	Files []*FileResult // sorted by filename, files that failed are left out
+}
+
+// ErrorBlocks returns the number of blocks in all files that could not be parsed.
+func (r *Result) ErrorBlocks() int {
+	n := 0
+	for _, f := range r.Files {
+		n += f.ErrorBlocks
+	}
+
+	return n
+}
+
+// ChangedFiles returns the files with blocks that were formatted, or that need formatting for
+// CheckPath.
+func (r *Result) ChangedFiles() []*FileResult {
+	var changed []*FileResult
+	for _, f := range r.Files {
+		if f.Changed() {
+			changed = append(changed, f)
+		}
+	}
+
+	return changed
+}
+
+// FormatPath formats the blocks of every file found for path (see Files) in place, with
+// opts.Parallelism files at a time (see ProcessFiles).
+func FormatPath(ctx context.Context, fs afero.Fs, path string, opts Options) (*Result, error) {
+	return processPath(ctx, fs, path, opts, FormatFile)
+}
+
+// CheckPath reports which blocks of the files found for path (see Files) need formatting, without
+// changing them, with opts.Parallelism files at a time (see ProcessFiles).
+func CheckPath(ctx context.Context, fs afero.Fs, path string, opts Options) (*Result, error) {
+	return processPath(ctx, fs, path, opts, DiffFile)
+}
+
+type fileFunc func(ctx context.Context, fs afero.Fs, filename string, opts Options) (*FileResult, error)
+
+// processPath calls process for every file found for path on the ProcessFiles pool. The errors of
+// individual files are merged into the returned error, a file that fails does not stop the others.
+func processPath(ctx context.Context, fs afero.Fs, path string, opts Options, process fileFunc) (*Result, error) {
+	filenames, err := Files(fs, path, opts.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	type fileOutcome struct {
+		res *FileResult
+		err error
+	}
+
+	result := &Result{}
+	var merr *multierror.Error
+	err = ProcessFiles(ctx, filenames, opts.Parallelism,
+		func(filename string) fileOutcome {
+			res, err := process(ctx, fs, filename, opts)
+			return fileOutcome{res, err}
+		},
+		func(_ string, o fileOutcome) {
+			if o.err != nil {
+				merr = multierror.Append(merr, o.err)
+				return
+			}
+			if o.res != nil {
+				result.Files = append(result.Files, o.res)
+			}
+		},
+	)
+	if err != nil {
+		merr = multierror.Append(merr, err)
+	}
+
+	return result, merr.ErrorOrNil()
+}
@@ -0,0 +1,34 @@
+package terrafmt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFilesSkipsTerraformFiles(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	for _, filename := range []string{
+		"examples/basic/main.tf",
+		"examples/basic/.terraform/modules/network/main.tf",
+		"examples/basic/.terraform.lock.hcl",
+		"examples/basic/README.md",
+	} {
+		if err := afero.WriteFile(fs, filename, []byte{}, 0o644); err != nil {
+			t.Fatalf("Error writing %q: %s", filename, err)
+		}
+	}
+
+	filenames, err := Files(fs, "examples", "")
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	expected := []string{"examples/basic/README.md", "examples/basic/main.tf"}
+	if !reflect.DeepEqual(filenames, expected) {
+		t.Errorf("Expected files %v, got %v", expected, filenames)
+	}
+}
@@ -0,0 +1,170 @@
+package terrafmt
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"slices"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/fmtverbs"
+	"github.com/spf13/afero"
+)
+
+// PositionalVerbs rewrites the go blocks of filename so each of their format verbs picks its argument
+// with an explicit index (%s becomes %[1]s), updating the fmt call the block is the format of to match:
+// an argument the call repeats (the same variable, field, or literal) is only passed once, and the
+// verbs that used each copy use it instead.
+//
+// Blocks without verbs, blocks that are not the format of a fmt call (e.g. passed to a helper
+// function), and blocks already using explicit indexes with no repeated arguments are left as they
+// are, as are the blocks of files that are not go. A block with sequential verbs is refused, and
+// counted as an error block, when its call can not be resolved: it is assigned to a const (which other
+// code could use too) or the call spreads its arguments (args...). So is a block where a verb uses an
+// argument the call does not have, or an argument is not used by any verb.
+func PositionalVerbs(ctx context.Context, fs afero.Fs, filename string, opts Options) (*FileResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &FileResult{}
+
+	br := blocks.Reader{
+		Log:      opts.logger(),
+		Select:   opts.Select,
+		LineRead: blocks.ReaderPassthrough,
+		BlockRead: func(br *blocks.Reader, _ int, b string, _ bool) error {
+			cb := br.CurrentBlock()
+			block := BlockResult{
+				Number:    br.BlockCount,
+				StartLine: cb.StartLine,
+				EndLine:   cb.EndLine,
+				Original:  b,
+				Formatted: b,
+			}
+
+			if br.CurrentNodeCursor == nil {
+				_, err := br.Writer.Write([]byte(b))
+				result.Blocks = append(result.Blocks, block)
+
+				return err
+			}
+
+			text, args, err := positionalBlock(br, b)
+			if err == nil && text != b {
+				// a codemod keeps the literals of a chain as they are, or leaves the block alone
+				err = br.ReplaceCurrentString(goBlockValue(br, text))
+			}
+			if err != nil {
+				block.Status = BlockError
+				block.Formatted = ""
+				block.Err = err
+				result.Blocks = append(result.Blocks, block)
+
+				return err
+			}
+
+			if text != b {
+				block.Status = BlockFormatted
+				block.Formatted = text
+				result.ChangedBlocks++
+
+				call := br.CurrentFormatCalls()[0]
+				if len(args) != len(call.Args) {
+					br.ReplaceFormatArgs(call, args)
+				}
+			}
+			result.Blocks = append(result.Blocks, block)
+
+			return nil
+		},
+	}
+	err := br.DoTheThing(fs, filename, opts.Stdin, opts.Stdout)
+
+	return result.fromReader(&br), err
+}
+
+// positionalBlock returns the current go block, b, with explicit argument indexes, and the arguments
+// its fmt call should have for it
+func positionalBlock(br *blocks.Reader, b string) (string, []string, error) {
+	verbs := fmtverbs.Verbs(b)
+	calls := br.CurrentFormatCalls()
+	if len(verbs) == 0 || len(calls) == 0 {
+		return b, nil, nil
+	}
+
+	used := fmtverbs.Arguments(verbs)
+	sequential := slices.ContainsFunc(used, func(a fmtverbs.Argument) bool { return !a.Indexed })
+
+	// the indexes a block already has do not depend on the call, only sequential verbs need rewriting
+	switch {
+	case len(calls) > 1 || calls[0].Const != "":
+		if !sequential {
+			return b, nil, nil
+		}
+
+		return "", nil, fmt.Errorf("it is the const %s, which other code could use too", calls[0].Const)
+	case calls[0].Spreads:
+		if !sequential {
+			return b, nil, nil
+		}
+
+		return "", nil, fmt.Errorf("the arguments of the %s call on line %d are spread", calls[0].Func, calls[0].Pos.Line)
+	}
+	call := calls[0]
+
+	isUsed := make([]bool, len(call.Args))
+	for _, a := range used {
+		if a.Number < 1 || a.Number > len(call.Args) {
+			return "", nil, fmt.Errorf("%s uses argument %d, but the %s call on line %d only has %d", a.Verb.Text, a.Number, call.Func, call.Pos.Line, len(call.Args))
+		}
+		isUsed[a.Number-1] = true
+	}
+
+	// fmt reports an unused argument in the output, merging it into a copy that is used would hide that
+	for i, u := range isUsed {
+		if !u {
+			return "", nil, fmt.Errorf("argument %d (%s) of the %s call on line %d is not used by any verb", i+1, call.ArgText[i], call.Func, call.Pos.Line)
+		}
+	}
+
+	// repeated arguments are merged, unless they could have side effects (e.g. a call)
+	var args []string
+	number := make([]int, len(call.Args))
+	first := map[string]int{}
+	for i, arg := range call.Args {
+		text := call.ArgText[i]
+		if n, ok := first[text]; ok && isPlainExpr(arg) {
+			number[i] = n
+
+			continue
+		}
+
+		args = append(args, text)
+		number[i] = len(args)
+		first[text] = len(args)
+	}
+
+	if !sequential && len(args) == len(call.Args) {
+		return b, nil, nil
+	}
+
+	return fmtverbs.Positional(b, func(arg int) int { return number[arg-1] }), args, nil
+}
+
+// isPlainExpr reports if expr is a name, a literal, or a field or element of one, so using it once
+// instead of several times does not change the result of a call
+func isPlainExpr(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isPlainExpr(e.X)
+	case *ast.SelectorExpr:
+		return isPlainExpr(e.X)
+	case *ast.IndexExpr:
+		return isPlainExpr(e.X) && isPlainExpr(e.Index)
+	}
+
+	return false
+}
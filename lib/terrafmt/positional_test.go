@@ -0,0 +1,107 @@
+package terrafmt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/spf13/afero"
+)
+
+func TestPositionalVerbs(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name        string
+		source      string
+		expected    string
+		changed     int
+		errorBlocks int
+	}{
+		{
+			name:     "sequential",
+			source:   "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name     = \"acctestRG-%d\"\n  location = %q\n}\n`, data.RandomInteger, data.Locations.Primary)\n}\n",
+			expected: "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name     = \"acctestRG-%[1]d\"\n  location = %[2]q\n}\n`, data.RandomInteger, data.Locations.Primary)\n}\n",
+			changed:  1,
+		},
+		{
+			name:     "repeated arguments",
+			source:   "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d-%s\"\n}\n\nresource \"azurerm_storage_account\" \"test\" {\n  name = \"acctestsa%d%s\"\n}\n`,\n\t\tdata.RandomInteger,\n\t\tacctest.RandString(5),\n\t\tdata.RandomInteger,\n\t\tacctest.RandString(5),\n\t)\n}\n",
+			expected: "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%[1]d-%[2]s\"\n}\n\nresource \"azurerm_storage_account\" \"test\" {\n  name = \"acctestsa%[1]d%[3]s\"\n}\n`,\n\t\tdata.RandomInteger,\n\t\tacctest.RandString(5),\n\t\tacctest.RandString(5),\n\t)\n}\n",
+			changed:  1,
+		},
+		{
+			name:   "already positional",
+			source: "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%[1]d-%[1]d\"\n}\n`, data.RandomInteger)\n}\n",
+		},
+		{
+			name:        "const",
+			source:      "package a\n\nimport \"fmt\"\n\nconst tmpl = `\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(tmpl, data.RandomInteger)\n}\n",
+			errorBlocks: 1,
+		},
+		{
+			name:   "const with explicit indexes",
+			source: "package a\n\nimport \"fmt\"\n\nconst tmpl = `\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%[1]d\"\n}\n`\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(tmpl, data.RandomInteger)\n}\n",
+		},
+		{
+			// a block that is not the format of a fmt call is left to whatever it is passed to
+			name:   "helper",
+			source: "package a\n\nfunc testAcc(data TestData) string {\n\treturn template(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`, data.RandomInteger)\n}\n",
+		},
+		{
+			name:   "no verbs",
+			source: "package a\n\nconst config = `\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG\"\n}\n`\n\nfunc testAcc() string {\n\treturn `\nresource \"azurerm_resource_group\" \"other\" {\n  name = \"acctestRG-other\"\n}\n`\n}\n",
+		},
+		{
+			name:        "missing argument",
+			source:      "package a\n\nimport \"fmt\"\n\nfunc testAcc(data TestData) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d-%s\"\n}\n`, data.RandomInteger)\n}\n",
+			errorBlocks: 1,
+		},
+		{
+			// after %[1]d the last %s is argument 2, so the repeated s is never used
+			name:        "unused argument",
+			source:      "package a\n\nimport \"fmt\"\n\nfunc testAcc(n int, s string) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name     = \"acctestRG-%d-%s\"\n  location = \"%[1]d-%s\"\n}\n`, n, s, s)\n}\n",
+			errorBlocks: 1,
+		},
+		{
+			name:        "chain split in a line",
+			source:      "package a\n\nimport \"fmt\"\n\nfunc testAcc(n int) string {\n\treturn fmt.Sprintf(\"resource \\\"azurerm_resource_group\\\" \\\"test\\\" {\\n  name = \" +\n\t\t\"\\\"acctestRG-%d\\\"\\n}\\n\", n)\n}\n",
+			errorBlocks: 1,
+		},
+		{
+			name:     "chain",
+			source:   "package a\n\nimport \"fmt\"\n\nfunc testAcc(n int) string {\n\treturn fmt.Sprintf(\"resource \\\"azurerm_resource_group\\\" \\\"test\\\" {\\n\" +\n\t\t\"  name = \\\"acctestRG-%d-%d\\\"\\n\" +\n\t\t\"}\\n\", n, n)\n}\n",
+			expected: "package a\n\nimport \"fmt\"\n\nfunc testAcc(n int) string {\n\treturn fmt.Sprintf(\"resource \\\"azurerm_resource_group\\\" \\\"test\\\" {\\n\" +\n\t\t\"  name = \\\"acctestRG-%[1]d-%[1]d\\\"\\n\" +\n\t\t\"}\\n\", n)\n}\n",
+			changed:  1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newTestFs(t, map[string]string{"a_test.go": testcase.source})
+
+			res, err := PositionalVerbs(context.Background(), fs, "a_test.go", Options{})
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if res.ChangedBlocks != testcase.changed || res.ErrorBlocks != testcase.errorBlocks {
+				t.Errorf("Expected %d changed and %d error blocks, got %d and %d", testcase.changed, testcase.errorBlocks, res.ChangedBlocks, res.ErrorBlocks)
+			}
+
+			expected := testcase.expected
+			if expected == "" {
+				expected = testcase.source
+			}
+
+			data, err := afero.ReadFile(fs, "a_test.go")
+			if err != nil {
+				t.Fatalf("Error reading a_test.go: %s", err)
+			}
+			if string(data) != expected {
+				t.Errorf("File differs:\n%s", diff.Diff(string(data), expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/spf13/afero"
+)
+
+// ReplaceBlock replaces the text of the one block of filename that sel selects with text, which is
+// written the way the block is embedded: re-quoted for go string literals, and indented to the level
+// of the block for indented (rst, markdown list item) blocks, keeping the newlines the block ended
+// with. The indentation text already has, e.g. as the blocks command prints an rst block, is replaced
+// rather than added to. It is an error for sel to select no block or more than one.
+func ReplaceBlock(ctx context.Context, fs afero.Fs, filename string, sel blocks.Selection, text string, opts Options) (*FileResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, errors.New("a block can only be replaced in a file")
+	}
+	if sel.IsZero() {
+		return nil, errors.New("the block to replace must be selected")
+	}
+
+	var found []BlockEdit
+	br := blocks.Reader{
+		Log:      opts.logger(),
+		ReadOnly: true,
+		Select:   sel,
+		LineRead: blocks.ReaderIgnore,
+		BlockRead: func(br *blocks.Reader, _ int, b string, preserveIndent bool) error {
+			// the block keeps the line endings it had, e.g. the blank line ending an rst block
+			edit := BlockEdit{
+				Number:   br.BlockCount,
+				Original: b,
+				Text:     strings.TrimRight(text, "\n") + b[len(strings.TrimRight(b, "\n")):],
+			}
+			if preserveIndent {
+				edit.Text = indentToOriginalLevel(dedent(edit.Text), b)
+			}
+			found = append(found, edit)
+
+			return nil
+		},
+	}
+	if err := br.DoTheThing(fs, filename, nil, io.Discard); err != nil {
+		return nil, err
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%s: no block is selected", filename)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%s: %d blocks are selected, only one can be replaced", filename, len(found))
+	}
+
+	return ApplyEdits(ctx, fs, filename, found, opts)
+}
@@ -0,0 +1,135 @@
+package terrafmt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/kylelemons/godebug/diff"
+	"github.com/spf13/afero"
+)
+
+func TestReplaceBlock(t *testing.T) {
+	t.Parallel()
+
+	text := "locals {\n  a = \"b\"\n}\n"
+
+	testcases := []struct {
+		name      string
+		filename  string
+		source    string
+		selection blocks.Selection
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name:      "markdown",
+			filename:  "README.md",
+			source:    "# Example\n\n```hcl\nlocals {}\n```\n\n```hcl\nlocals {}\n```\n",
+			selection: blocks.Selection{Number: 2},
+			expected:  "# Example\n\n```hcl\nlocals {}\n```\n\n```hcl\n" + text + "```\n",
+		},
+		{
+			name:      "markdown list item",
+			filename:  "README.md",
+			source:    "1. Example:\n\n    ```hcl\n    locals {}\n    ```\n",
+			selection: blocks.Selection{Line: 4},
+			expected:  "1. Example:\n\n    ```hcl\n    locals {\n      a = \"b\"\n    }\n    ```\n",
+		},
+		{
+			name:      "rst",
+			filename:  "index.rst",
+			source:    "Example\n\n.. code-block:: hcl\n\n   locals {}\n\nText\n",
+			selection: blocks.Selection{Number: 1},
+			expected:  "Example\n\n.. code-block:: hcl\n\n   locals {\n     a = \"b\"\n   }\n\nText\n",
+		},
+		{
+			name:      "go",
+			filename:  "main_test.go",
+			source:    "package main\n\nfunc testConfig() string {\n\treturn \"locals {}\\n\"\n}\n",
+			selection: blocks.Selection{Func: "testConfig"},
+			expected:  "package main\n\nfunc testConfig() string {\n\treturn \"locals {\\n  a = \\\"b\\\"\\n}\\n\"\n}\n",
+		},
+		{
+			name:      "nothing selected",
+			filename:  "README.md",
+			source:    "# Example\n\n```hcl\nlocals {}\n```\n",
+			selection: blocks.Selection{Number: 2},
+			wantErr:   true,
+		},
+		{
+			name:      "more than one selected",
+			filename:  "main_test.go",
+			source:    "package main\n\nfunc testConfig() string {\n\ta := \"locals {}\\n\"\n\tb := \"locals {}\\n\"\n\treturn a + b\n}\n",
+			selection: blocks.Selection{Func: "testConfig"},
+			wantErr:   true,
+		},
+		{
+			name:     "no selection",
+			filename: "README.md",
+			source:   "# Example\n\n```hcl\nlocals {}\n```\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newTestFs(t, map[string]string{testcase.filename: testcase.source})
+
+			_, err := ReplaceBlock(context.Background(), fs, testcase.filename, testcase.selection, text, Options{})
+			if testcase.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got none")
+				}
+				testcase.expected = testcase.source
+			} else if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+
+			data, err := afero.ReadFile(fs, testcase.filename)
+			if err != nil {
+				t.Fatalf("Error reading %q: %s", testcase.filename, err)
+			}
+			if string(data) != testcase.expected {
+				t.Errorf("File differs:\n%s", diff.Diff(string(data), testcase.expected))
+			}
+		})
+	}
+}
+
+func TestReplaceBlockUnchanged(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"index.rst": "Example\n\n.. code-block:: hcl\n\n   locals {}\n\n.. code-block:: hcl\n\n   locals {\n     a = \"b\"\n   }\n\nText\n",
+		"README.md": "1. Example:\n\n    ```hcl\n    locals {\n      a = \"b\"\n    }\n    ```\n",
+	}
+
+	for filename, source := range sources {
+		t.Run(filename, func(t *testing.T) {
+			t.Parallel()
+
+			// each block is replaced with its text as the blocks command prints it, indentation and all
+			for b, err := range blocks.ScanFile(filename, []byte(source), blocks.KindForFile(filename)) {
+				if err != nil {
+					t.Fatalf("Got an error when none was expected: %v", err)
+				}
+
+				fs := newTestFs(t, map[string]string{filename: source})
+				if _, err := ReplaceBlock(context.Background(), fs, filename, blocks.Selection{Number: b.Number}, b.Text, Options{}); err != nil {
+					t.Fatalf("Got an error when none was expected: %v", err)
+				}
+
+				data, err := afero.ReadFile(fs, filename)
+				if err != nil {
+					t.Fatalf("Error reading %q: %s", filename, err)
+				}
+				if string(data) != source {
+					t.Errorf("Replacing block %d with its own text changed the file:\n%s", b.Number, diff.Diff(string(data), source))
+				}
+			}
+		})
+	}
+}
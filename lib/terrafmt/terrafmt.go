@@ -0,0 +1,277 @@
+// Package terrafmt formats the terraform blocks embedded in files. It is what the terrafmt commands
+// are built on, and is meant for tools that embed terrafmt rather than running the binary: nothing
+// is printed and the process is never exited, everything is returned as structured results.
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"strings"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/format"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// Options configures how files are found and their blocks formatted.
+type Options struct {
+	// FmtCompat escapes go format verbs (%s, %d, ...) in blocks so they can be formatted
+	FmtCompat bool
+	// FixFinishLines removes the leading spaces of the line closing a block in go files
+	FixFinishLines bool
+	// Pattern is a glob matched against the base name of each file found when walking a directory
+	Pattern string
+	// Select picks the blocks of each file to process, the rest are left as they are
+	Select blocks.Selection
+	// Parallelism is the number of files processed at the same time by the path functions,
+	// GOMAXPROCS when less than 1
+	Parallelism int
+
+	// Log receives debug messages and the reasons blocks could not be formatted, nil discards them
+	Log *logrus.Logger
+
+	// Stdin is read for the empty filename, and FormatFile writes the formatted document to Stdout
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+func (o Options) logger() *logrus.Logger {
+	if o.Log != nil {
+		return o.Log
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	return log
+}
+
+// BlockStatus is the outcome of formatting a single block.
+type BlockStatus int
+
+const (
+	// BlockUnchanged is a block that is already formatted
+	BlockUnchanged BlockStatus = iota
+	// BlockNeedsFormatting is a block DiffFile found to need formatting
+	BlockNeedsFormatting
+	// BlockFormatted is a block FormatFile formatted
+	BlockFormatted
+	// BlockError is a block that could not be parsed, see BlockResult.Err
+	BlockError
+)
+
+func (s BlockStatus) String() string {
+	switch s {
+	case BlockUnchanged:
+		return "unchanged"
+	case BlockNeedsFormatting:
+		return "needs formatting"
+	case BlockFormatted:
+		return "formatted"
+	case BlockError:
+		return "error"
+	default:
+		return fmt.Sprintf("BlockStatus(%d)", int(s))
+	}
+}
+
+// BlockResult describes a single block found in a file.
+type BlockResult struct {
+	Number    int // 1 based, in the order the blocks appear in the file
+	StartLine int // the line before the first line of the block content
+	EndLine   int // the last line of the block
+	Status    BlockStatus
+	Original  string
+	Formatted string // empty when the block could not be parsed
+	Err       error
+
+	// where the first and last byte of the block's content are in the host file
+	ContentStart token.Position
+	ContentEnd   token.Position
+
+	// Diagnostics of a block that could not be parsed, positioned in the host file
+	Diagnostics []Diagnostic
+}
+
+// FileResult describes the blocks found in a file and what was done with them.
+type FileResult struct {
+	Filename      string // "stdin" when the document was read from Options.Stdin
+	Lines         int
+	Blocks        []BlockResult
+	BlockCount    int // blocks found, including any whose end could not be found and so are not in Blocks
+	ErrorBlocks   int // blocks that could not be parsed
+	ChangedBlocks int // blocks that were formatted, or that need formatting for DiffFile
+
+	// the document as it was read and the kind of host it is, see FormattedSource and Patch
+	Source []byte
+	Kind   blocks.Kind
+}
+
+// Changed reports whether any of the file's blocks were formatted, or need formatting for DiffFile.
+func (r *FileResult) Changed() bool {
+	return r.ChangedBlocks > 0
+}
+
+// FormatFile formats the blocks in filename, replacing the file if any of them changed. An empty
+// filename formats the document read from opts.Stdin and writes it to opts.Stdout.
+//
+// The result is returned even when there is an error, describing the blocks processed before it.
+func FormatFile(ctx context.Context, fs afero.Fs, filename string, opts Options) (*FileResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log := opts.logger()
+	result := &FileResult{}
+
+	br := blocks.Reader{
+		Log:      log,
+		LineRead: blocks.ReaderPassthrough,
+		BlockRead: func(br *blocks.Reader, _ int, b string, preserveIndent bool) error {
+			block, err := formatBlock(log, br, filename, b, preserveIndent, opts.FmtCompat)
+			if err != nil {
+				result.Blocks = append(result.Blocks, block)
+				return err
+			}
+			fb := block.Formatted
+
+			hasChange := fb != b
+
+			if br.CurrentNodeCursor != nil {
+				fb = strings.TrimSuffix(fb, "\n")
+
+				if br.FixFinishLines {
+					trimmed := strings.TrimRight(br.CurrentNodeTrailingPadding, " \t")
+					if trimmed != br.CurrentNodeTrailingPadding {
+						br.CurrentNodeTrailingPadding = trimmed
+						hasChange = true
+					}
+				}
+
+				if hasChange {
+					replaceGoBlock(br, fb)
+				}
+			} else {
+				_, err = br.Writer.Write([]byte(fb))
+			}
+
+			if err == nil && hasChange {
+				block.Status = BlockFormatted
+				result.ChangedBlocks++
+			}
+			result.Blocks = append(result.Blocks, block)
+
+			return err
+		},
+		FixFinishLines: opts.FixFinishLines,
+		Select:         opts.Select,
+	}
+	err := br.DoTheThing(fs, filename, opts.Stdin, opts.Stdout)
+
+	return result.fromReader(&br), err
+}
+
+// DiffFile formats the blocks in filename without changing the file, reporting which of them need
+// formatting. An empty filename reads the document from opts.Stdin.
+func DiffFile(ctx context.Context, fs afero.Fs, filename string, opts Options) (*FileResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log := opts.logger()
+	result := &FileResult{}
+
+	br := blocks.Reader{
+		Log:      log,
+		ReadOnly: true,
+		Select:   opts.Select,
+		LineRead: blocks.ReaderPassthrough,
+		BlockRead: func(br *blocks.Reader, _ int, b string, preserveIndent bool) error {
+			block, err := formatBlock(log, br, filename, b, preserveIndent, opts.FmtCompat)
+			if err == nil && block.Formatted != b {
+				block.Status = BlockNeedsFormatting
+				result.ChangedBlocks++
+			}
+			result.Blocks = append(result.Blocks, block)
+
+			return err
+		},
+	}
+
+	if err := br.DoTheThing(fs, filename, opts.Stdin, io.Discard); err != nil {
+		return nil, err
+	}
+
+	return result.fromReader(&br), nil
+}
+
+// goBlockValue returns the value of the string expression of the go block the reader is currently on
+// with its text replaced by text, keeping the padding around it
+func goBlockValue(br *blocks.Reader, text string) string {
+	return br.CurrentNodeLeadingPadding + strings.TrimSuffix(text, "\n") + br.CurrentNodeTrailingPadding
+}
+
+// replaceGoBlock replaces the text of the go block the reader is currently on with text. A + chain is
+// rewritten literal by literal when it can be, and written back as a single literal when it is not
+// split at line ends.
+func replaceGoBlock(br *blocks.Reader, text string) {
+	value := goBlockValue(br, text)
+	if err := br.ReplaceCurrentString(value); err != nil {
+		br.ReplaceCurrentNode(blocks.GoStringLiteral(br.CurrentNodeQuoteChar, value))
+	}
+}
+
+// formatBlock formats the block the reader is currently on, the returned block is unchanged unless
+// it could not be parsed
+func formatBlock(log *logrus.Logger, br *blocks.Reader, filename, b string, preserveIndent, fmtCompat bool) (BlockResult, error) {
+	block := BlockResult{
+		Number:    br.BlockCount,
+		StartLine: br.LineCount - br.BlockCurrentLine,
+		EndLine:   br.LineCount,
+		Original:  b,
+	}
+	block.ContentStart, _ = br.BlockPosition(0)
+	block.ContentEnd, _ = br.BlockPosition(max(len(b)-1, 0))
+
+	var fb string
+	var err error
+	if fmtCompat {
+		fb, err = format.FmtVerbBlock(log, b, filename)
+	} else {
+		fb, err = format.Block(log, b, filename)
+	}
+	if err != nil {
+		err = hostParseError(br, err)
+		block.Status = BlockError
+		block.Err = err
+
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			block.Diagnostics = parseErr.Diagnostics
+		}
+
+		return block, err
+	}
+
+	if preserveIndent {
+		fb = indentToOriginalLevel(fb, b)
+	}
+	block.Formatted = fb
+
+	return block, nil
+}
+
+func (r *FileResult) fromReader(br *blocks.Reader) *FileResult {
+	r.Filename = br.FileName
+	r.Lines = br.LineCount
+	r.BlockCount = br.BlockCount
+	r.ErrorBlocks = br.ErrorBlocks
+	r.Source = br.Source
+	r.Kind = br.Kind
+
+	return r
+}
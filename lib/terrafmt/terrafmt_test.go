@@ -0,0 +1,216 @@
+package terrafmt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+const unformattedMarkdown = "# Example\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n  bucket =    \"example\"\n}\n```\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n```\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n```\n"
+
+const formattedMarkdown = "# Example\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n```\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example\"\n}\n```\n\n```hcl\nresource \"aws_s3_bucket\" \"example\" {\n```\n"
+
+func newTestFs(t *testing.T, files map[string]string) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	for filename, content := range files {
+		if err := afero.WriteFile(fs, filename, []byte(content), 0o644); err != nil {
+			t.Fatalf("Error writing %q: %s", filename, err)
+		}
+	}
+
+	return fs
+}
+
+func TestDiffFile(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{"README.md": unformattedMarkdown})
+
+	res, err := DiffFile(context.Background(), fs, "README.md", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if res.Filename != "README.md" || res.Lines != 17 || res.BlockCount != 3 {
+		t.Errorf("Expected README.md with 17 lines and 3 blocks, got %s with %d lines and %d blocks", res.Filename, res.Lines, res.BlockCount)
+	}
+	if res.ChangedBlocks != 1 || res.ErrorBlocks != 1 || !res.Changed() {
+		t.Errorf("Expected 1 block to need formatting and 1 error, got %d and %d", res.ChangedBlocks, res.ErrorBlocks)
+	}
+
+	expected := []struct {
+		startLine int
+		status    BlockStatus
+	}{
+		{3, BlockNeedsFormatting},
+		{9, BlockUnchanged},
+		{15, BlockError},
+	}
+	if len(res.Blocks) != len(expected) {
+		t.Fatalf("Expected %d blocks, got %d", len(expected), len(res.Blocks))
+	}
+	for i, e := range expected {
+		b := res.Blocks[i]
+		if b.Number != i+1 || b.StartLine != e.startLine || b.Status != e.status {
+			t.Errorf("Block %d: expected number %d @ %d %s, got %d @ %d %s", i, i+1, e.startLine, e.status, b.Number, b.StartLine, b.Status)
+		}
+		if (b.Status == BlockError) != (b.Err != nil) {
+			t.Errorf("Block %d: unexpected error %v for status %s", i, b.Err, b.Status)
+		}
+	}
+
+	data, err := afero.ReadFile(fs, "README.md")
+	if err != nil {
+		t.Fatalf("Error reading README.md: %s", err)
+	}
+	if string(data) != unformattedMarkdown {
+		t.Errorf("Expected DiffFile to leave the file unchanged, got:\n%s", data)
+	}
+}
+
+func TestFormatFile(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{"README.md": unformattedMarkdown})
+
+	res, err := FormatFile(context.Background(), fs, "README.md", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if res.ChangedBlocks != 1 || res.ErrorBlocks != 1 {
+		t.Errorf("Expected 1 formatted block and 1 error, got %d and %d", res.ChangedBlocks, res.ErrorBlocks)
+	}
+	if s := res.Blocks[0].Status; s != BlockFormatted {
+		t.Errorf("Expected the first block to be %s, got %s", BlockFormatted, s)
+	}
+
+	data, err := afero.ReadFile(fs, "README.md")
+	if err != nil {
+		t.Fatalf("Error reading README.md: %s", err)
+	}
+	if string(data) != formattedMarkdown {
+		t.Errorf("Expected the file to be formatted, got:\n%s", data)
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{
+		"docs/b.md":      unformattedMarkdown,
+		"docs/a.md":      formattedMarkdown,
+		"docs/notes.txt": unformattedMarkdown,
+	})
+
+	res, err := CheckPath(context.Background(), fs, "docs", Options{Pattern: "*.md", Parallelism: 2})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if len(res.Files) != 2 || res.Files[0].Filename != "docs/a.md" || res.Files[1].Filename != "docs/b.md" {
+		t.Fatalf("Expected results for docs/a.md and docs/b.md, got %v", res.Files)
+	}
+	if changed := res.ChangedFiles(); len(changed) != 1 || changed[0].Filename != "docs/b.md" {
+		t.Errorf("Expected only docs/b.md to need formatting, got %v", changed)
+	}
+	if n := res.ErrorBlocks(); n != 2 {
+		t.Errorf("Expected 2 error blocks, got %d", n)
+	}
+}
+
+func TestFormatPathCancelled(t *testing.T) {
+	t.Parallel()
+
+	fs := newTestFs(t, map[string]string{"docs/README.md": unformattedMarkdown})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := FormatPath(ctx, fs, "docs", Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+	if len(res.Files) != 0 {
+		t.Errorf("Expected no files to be processed, got %d", len(res.Files))
+	}
+
+	data, err := afero.ReadFile(fs, "docs/README.md")
+	if err != nil {
+		t.Fatalf("Error reading docs/README.md: %s", err)
+	}
+	if string(data) != unformattedMarkdown {
+		t.Errorf("Expected the file to be left unchanged, got:\n%s", data)
+	}
+}
+
+func TestDiagnosticsInHostFile(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		filename  string
+		content   string
+		annotated string
+	}{
+		{
+			name:      "markdown",
+			filename:  "README.md",
+			content:   "# Example\n\n```hcl\nresource \"a\" \"b\" {\n  x =\n}\n```\n",
+			annotated: "README.md:5:6: error: Invalid expression; Expected the start of an expression, but found an invalid expression token.\n  x =\n     ^\n",
+		},
+		{
+			name:      "rst",
+			filename:  "index.rst",
+			content:   "Example\n\n.. code:: terraform\n\n   resource \"a\" \"b\" {\n   \tx =\n   }\n",
+			annotated: "index.rst:6:8: error: Invalid expression; Expected the start of an expression, but found an invalid expression token.\n   \tx =\n   \t   ^\n",
+		},
+		{
+			name:      "go raw",
+			filename:  "main_test.go",
+			content:   "package main\n\nconst config = `\nresource \"a\" \"b\" {\n  x =\n}\n`\n",
+			annotated: "main_test.go:5:6: error: Invalid expression; Expected the start of an expression, but found an invalid expression token.\n  x =\n     ^\n",
+		},
+		{
+			name:      "go interpreted",
+			filename:  "main_test.go",
+			content:   "package main\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  x =\\n}\\n\"\n",
+			annotated: "main_test.go:3:44: error: Invalid expression; Expected the start of an expression, but found an invalid expression token.\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  x =\\n}\\n\"\n                                           ^\n",
+		},
+		{
+			name:      "go unicode escape",
+			filename:  "main_test.go",
+			content:   "package main\n\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  y = \\\"\\u00e9\\\"\\n  x =\\n}\\n\"\n",
+			annotated: "main_test.go:3:62: error: Invalid expression; Expected the start of an expression, but found an invalid expression token.\nvar config = \"resource \\\"a\\\" \\\"b\\\" {\\n  y = \\\"\\u00e9\\\"\\n  x =\\n}\\n\"\n                                                             ^\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newTestFs(t, map[string]string{testcase.filename: testcase.content})
+
+			res, err := DiffFile(context.Background(), fs, testcase.filename, Options{})
+			if err != nil {
+				t.Fatalf("Got an error when none was expected: %v", err)
+			}
+			if len(res.Blocks) != 1 || len(res.Blocks[0].Diagnostics) != 1 {
+				t.Fatalf("Expected a single block with a single diagnostic, got %+v", res.Blocks)
+			}
+
+			var parseErr *ParseError
+			if !errors.As(res.Blocks[0].Err, &parseErr) {
+				t.Errorf("Expected a parse error, got %v", res.Blocks[0].Err)
+			}
+
+			if actual := res.Blocks[0].Diagnostics[0].Annotated(); actual != testcase.annotated {
+				t.Errorf("Expected\n%s\ngot\n%s", testcase.annotated, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package terrafmt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// indentToOriginalLevel indents every line of formatted by the indentation of the first non blank
+// line of original, blank lines are left empty rather than given trailing whitespace
+func indentToOriginalLevel(formatted, original string) string {
+	prefix := ""
+	for _, r := range original {
+		if unicode.IsSpace(r) {
+			if r == '\n' {
+				prefix = ""
+				continue
+			}
+			prefix += string(r)
+		} else {
+			break
+		}
+	}
+
+	lines := strings.Split(formatted, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			lines[i] = prefix + l
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// dedent removes the indentation the non blank lines of s have in common, blank lines are left as they
+// are so indentToOriginalLevel gives back the same text
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+
+	prefix := ""
+	found := false
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if !found {
+			prefix, found = indent, true
+
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			lines[i] = strings.TrimPrefix(l, prefix)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
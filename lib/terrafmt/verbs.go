@@ -0,0 +1,175 @@
+package terrafmt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/token"
+	"io"
+	"slices"
+
+	"github.com/katbyte/terrafmt/lib/blocks"
+	"github.com/katbyte/terrafmt/lib/fmtverbs"
+	"github.com/spf13/afero"
+)
+
+// VerbsResult describes the go format verbs of the blocks in a file, checked against the arguments of
+// the fmt calls the blocks are the format of.
+type VerbsResult struct {
+	Filename    string
+	Blocks      int // blocks that are the format of a fmt call, and so were checked
+	Calls       int // fmt calls checked, a block assigned to a const can be the format of several
+	Diagnostics []Diagnostic
+}
+
+// CheckVerbs checks the format verbs of each go block of filename that is the format of a fmt call
+// (fmt.Sprintf, fmt.Errorf, ...), directly or through a const, against the arguments of the call. A
+// verb using an argument the call does not have, or an argument that no verb uses, is an error, and
+// a block mixing sequential verbs with explicit argument indexes (%[n]s) is a warning. Blocks whose
+// call can not be found statically, e.g. a format passed to a helper function, and calls spreading
+// their arguments (args...) are not checked. An empty filename checks the document read from
+// opts.Stdin.
+func CheckVerbs(ctx context.Context, fs afero.Fs, filename string, opts Options) (*VerbsResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log := opts.logger()
+	result := &VerbsResult{Filename: filename}
+	if filename == "" {
+		result.Filename = "stdin"
+	}
+
+	br := blocks.Reader{
+		Log:      log,
+		ReadOnly: true,
+		Select:   opts.Select,
+		LineRead: blocks.ReaderIgnore,
+		BlockRead: func(br *blocks.Reader, _ int, b string, _ bool) error {
+			calls := br.CurrentFormatCalls()
+			if len(calls) == 0 {
+				return nil
+			}
+			result.Blocks++
+
+			args := fmtverbs.Arguments(fmtverbs.Verbs(b))
+			for _, call := range calls {
+				if call.Spreads {
+					log.Debugf("block %d @ %s:%d: the arguments of %s @ %d are spread, so are not checked", br.BlockCount, result.Filename, br.CurrentBlock().StartLine, call.Func, call.Pos.Line)
+
+					continue
+				}
+				result.Calls++
+				result.Diagnostics = append(result.Diagnostics, checkCallArgs(br, call, args)...)
+			}
+
+			if d, ok := mixedArgIndexes(br, args); ok {
+				result.Diagnostics = append(result.Diagnostics, d)
+			}
+
+			return nil
+		},
+	}
+
+	if err := br.DoTheThing(fs, filename, opts.Stdin, io.Discard); err != nil {
+		return result, err
+	}
+
+	slices.SortStableFunc(result.Diagnostics, func(a, b Diagnostic) int {
+		return a.Pos.Offset - b.Pos.Offset
+	})
+
+	return result, nil
+}
+
+// checkCallArgs returns the diagnostics of the arguments of call, whose format is the current block
+func checkCallArgs(br *blocks.Reader, call blocks.FormatCall, args []fmtverbs.Argument) []Diagnostic {
+	var diags []Diagnostic
+
+	callName := fmt.Sprintf("the %s call on line %d", call.Func, call.Pos.Line)
+	if call.Const != "" {
+		callName += " (through " + call.Const + ")"
+	}
+
+	used := make([]bool, len(call.Args))
+	for _, a := range args {
+		if a.Number >= 1 && a.Number <= len(call.Args) {
+			used[a.Number-1] = true
+
+			continue
+		}
+
+		detail := fmt.Sprintf("%s uses argument %d, but %s only has %d", a.Verb.Text, a.Number, callName, len(call.Args))
+		if a.Number < 1 {
+			detail = a.Verb.Text + " uses argument 0, argument indexes start at 1"
+		}
+
+		pos, source := br.BlockPosition(a.Verb.Start)
+		diags = append(diags, Diagnostic{
+			Severity: "error",
+			Summary:  "Missing format argument",
+			Detail:   detail,
+			Pos:      pos,
+			Source:   source,
+		})
+	}
+
+	for i, u := range used {
+		if u {
+			continue
+		}
+
+		pos := call.ArgPos[i]
+		diags = append(diags, Diagnostic{
+			Severity: "error",
+			Summary:  "Unused format argument",
+			Detail:   fmt.Sprintf("argument %d of %s is not used by any verb of its format", i+1, callName),
+			Pos:      pos,
+			Source:   sourceLine(br.Source, pos),
+		})
+	}
+
+	return diags
+}
+
+// mixedArgIndexes returns a warning for a block with both verbs that pick their argument with an
+// index and verbs that use the next one, positioned at the first of the kind there are fewer of
+func mixedArgIndexes(br *blocks.Reader, args []fmtverbs.Argument) (Diagnostic, bool) {
+	var indexed, sequential []fmtverbs.Argument
+	for _, a := range args {
+		if a.Indexed {
+			indexed = append(indexed, a)
+		} else {
+			sequential = append(sequential, a)
+		}
+	}
+	if len(indexed) == 0 || len(sequential) == 0 {
+		return Diagnostic{}, false
+	}
+
+	odd := sequential[0]
+	if len(indexed) < len(sequential) {
+		odd = indexed[0]
+	}
+
+	pos, source := br.BlockPosition(odd.Verb.Start)
+
+	return Diagnostic{
+		Severity: "warning",
+		Summary:  "Mixed format argument indexes",
+		Detail:   fmt.Sprintf("%s uses the next argument while %s picks its own with an index, use one or the other", sequential[0].Verb.Text, indexed[0].Verb.Text),
+		Pos:      pos,
+		Source:   source,
+	}, true
+}
+
+// sourceLine returns the line of src pos is on, without its line ending
+func sourceLine(src []byte, pos token.Position) string {
+	start := bytes.LastIndexByte(src[:pos.Offset], '\n') + 1
+	end := bytes.IndexByte(src[pos.Offset:], '\n')
+	if end < 0 {
+		end = len(src) - pos.Offset
+	}
+
+	return string(bytes.TrimSuffix(src[start:pos.Offset+end], []byte("\r")))
+}
@@ -0,0 +1,52 @@
+package terrafmt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCheckVerbs(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nimport \"fmt\"\n\n" +
+		"const tmpl = `\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`\n\n" +
+		"func testAccConst(n int) string {\n\treturn fmt.Sprintf(tmpl, n, \"unused\")\n}\n\n" +
+		"func testAccMissing(n int) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name     = \"acctestRG-%[1]d\"\n  location = %[3]q\n}\n`, n, \"westus\")\n}\n\n" +
+		"func testAccMixed(n int) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name     = \"acctestRG-%[1]d\"\n  location = %q\n}\n`, n, \"westus\")\n}\n\n" +
+		"func testAccErrorf(n int) error {\n\treturn fmt.Errorf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`, n)\n}\n\n" +
+		"func testAccSpread(args ...any) string {\n\treturn fmt.Sprintf(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`, args...)\n}\n\n" +
+		"func testAccHelper() string {\n\treturn template(`\nresource \"azurerm_resource_group\" \"test\" {\n  name = \"acctestRG-%d\"\n}\n`)\n}\n"
+
+	fs := newTestFs(t, map[string]string{"a_test.go": src})
+
+	res, err := CheckVerbs(context.Background(), fs, "a_test.go", Options{})
+	if err != nil {
+		t.Fatalf("Got an error when none was expected: %v", err)
+	}
+
+	if res.Blocks != 5 || res.Calls != 4 {
+		t.Errorf("Expected 5 blocks in 4 calls to be checked, got %d in %d", res.Blocks, res.Calls)
+	}
+
+	var got []string
+	for _, d := range res.Diagnostics {
+		got = append(got, fmt.Sprintf("%d:%d %s %s", d.Pos.Line, d.Pos.Column, d.Severity, d.Summary))
+	}
+	expected := []string{
+		"12:30 error Unused format argument",
+		"19:14 error Missing format argument",
+		"21:7 error Unused format argument",
+		"28:14 warning Mixed format argument indexes",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected diagnostics:\n%v\ngot:\n%v", expected, got)
+	}
+
+	for _, d := range res.Diagnostics {
+		if d.Source == "" {
+			t.Errorf("Expected the source line of %s", d)
+		}
+	}
+}
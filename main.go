@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -16,6 +17,11 @@ func main() {
 	}
 
 	if err := root.Execute(); err != nil {
+		var exitCode cli.ExitCodeError
+		if errors.As(err, &exitCode) {
+			os.Exit(int(exitCode))
+		}
+
 		fmt.Fprint(os.Stderr, c.Sprintf("<red>terrafmt:</> %v\n", err))
 		os.Exit(1)
 	}